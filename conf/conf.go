@@ -211,6 +211,11 @@ func (p *Properties) Has(key string) bool {
 	return p.storage.Has(key)
 }
 
+// Subset returns the key and its sub keys with their values.
+func (p *Properties) Subset(key string) map[string]string {
+	return p.storage.Subset(key)
+}
+
 type getArg struct {
 	def string
 }
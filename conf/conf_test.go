@@ -395,6 +395,24 @@ func TestProperties_Has(t *testing.T) {
 	assert.False(t, p.Has("a.b.e"))
 }
 
+func TestProperties_Subset(t *testing.T) {
+	p, err := conf.Map(map[string]interface{}{
+		"a.b.c":  "3",
+		"a.b.d":  []string{"7", "8"},
+		"a.bc":   "9",
+		"a.e[0]": map[string]interface{}{"f": "1"},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, p.Subset("a.b"), map[string]string{
+		"a.b.c":    "3",
+		"a.b.d[0]": "7",
+		"a.b.d[1]": "8",
+	})
+	assert.Equal(t, p.Subset("a.b.c"), map[string]string{"a.b.c": "3"})
+	assert.Equal(t, p.Subset("a.e"), map[string]string{"a.e[0].f": "1"})
+	assert.Equal(t, p.Subset("a.x"), map[string]string(nil))
+}
+
 func TestProperties_Set(t *testing.T) {
 
 	t.Run("map nil", func(t *testing.T) {
@@ -132,6 +132,57 @@ func (s *Storage) SubKeys(key string) ([]string, error) {
 	return keys, nil
 }
 
+// Subset returns the key and its sub keys with their values.
+func (s *Storage) Subset(key string) map[string]string {
+	path, err := SplitPath(key)
+	if err != nil {
+		return nil
+	}
+	tree := s.tree
+	for _, pathNode := range path {
+		m, ok := tree.data.(map[string]*treeNode)
+		if !ok {
+			return nil
+		}
+		v, ok := m[pathNode.Elem]
+		if !ok {
+			return nil
+		}
+		tree = v
+	}
+	ret := make(map[string]string)
+	s.subset(key, tree, ret)
+	return ret
+}
+
+func (s *Storage) subset(key string, tree *treeNode, ret map[string]string) {
+	m, ok := tree.data.(map[string]*treeNode)
+	if !ok {
+		if val, ok := s.data[key]; ok {
+			ret[key] = val
+		}
+		return
+	}
+	for k, v := range m {
+		subKey := k
+		if tree.node == nodeTypeArray && isIndex(k) {
+			subKey = key + "[" + k + "]"
+		} else if key != "" {
+			subKey = key + "." + k
+		}
+		s.subset(subKey, v, ret)
+	}
+}
+
+func isIndex(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
 // Has returns whether the key exists.
 func (s *Storage) Has(key string) bool {
 	path, err := SplitPath(key)
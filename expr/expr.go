@@ -34,3 +34,17 @@ func Eval(input string, val interface{}) (bool, error) {
 	}
 	return b, nil
 }
+
+// EvalEnv returns the value for the expression expr, the variables and
+// functions used in the expression are looked up in env.
+func EvalEnv(input string, env map[string]interface{}) (bool, error) {
+	r, err := expr.Eval(input, env)
+	if err != nil {
+		return false, util.Wrapf(err, code.FileLine(), "eval %q returns error", input)
+	}
+	b, ok := r.(bool)
+	if !ok {
+		return false, util.Errorf(code.FileLine(), "eval %q doesn't return bool", input)
+	}
+	return b, nil
+}
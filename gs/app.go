@@ -27,6 +27,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/go-spring/spring-base/log"
@@ -57,6 +58,8 @@ type tempApp struct {
 	consumers   *Consumers
 	grpcServers *GrpcServers
 	banner      string
+	overrides   *conf.Properties
+	noModules   bool // 不使用全局的自动配置模块
 }
 
 // App 应用
@@ -69,9 +72,13 @@ type App struct {
 	b *bootstrap
 
 	exitChan chan struct{}
+	exitOnce sync.Once
+	exitMsg  string          // 关闭应用的原因
+	stopCtx  context.Context // 关闭应用时使用的 ctx
 
-	Events  []AppEvent  `autowire:"${application-event.collection:=*?}"`
-	Runners []AppRunner `autowire:"${command-line-runner.collection:=*?}"`
+	Events     []AppEvent  `autowire:"${application-event.collection:=*?}"`
+	Runners    []AppRunner `autowire:"${command-line-runner.collection:=*?}"`
+	Lifecycles []Lifecycle `autowire:"${lifecycle.collection:=*?}"`
 }
 
 type Consumers struct {
@@ -147,29 +154,69 @@ func (app *App) Run() error {
 	app.Object(app.router).Export((*web.Router)(nil))
 	app.logger = log.GetLogger(util.TypeName(app))
 
-	// 响应控制台的 Ctrl+C 及 kill 命令。
+	// 响应控制台的 Ctrl+C 及 kill 命令，Run 返回时停止接收信号。
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	defer close(done)
+	defer signal.Stop(ch)
 	go func() {
-		ch := make(chan os.Signal, 1)
-		signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
-		sig := <-ch
-		app.ShutDown(fmt.Sprintf("signal %v", sig))
+		select {
+		case sig := <-ch:
+			app.ShutDown(fmt.Sprintf("signal %v", sig))
+		case <-done:
+		}
 	}()
 
 	if err := app.start(); err != nil {
+		// 关闭启动失败之前已经创建的容器。
+		ctx, cancel := app.shutdownContext()
+		defer cancel()
+		app.closeContainers(ctx)
 		return err
 	}
 
 	<-app.exitChan
+	app.logger.Infof("program will exit %s", app.exitMsg)
 
-	if app.b != nil {
-		app.b.c.Close()
-	}
+	// 关闭过程的所有步骤共享 spring.shutdown.timeout 设置的超时时间。
+	ctx, cancel := app.shutdownContext()
+	defer cancel()
+	app.stopCtx = ctx
+
+	errs := app.stopLifecycles(ctx)
+	errs = append(errs, app.closeContainers(ctx)...)
 
-	app.c.Close()
 	app.logger.Info("application exited")
+	if len(errs) > 0 {
+		return &ShutdownError{Errors: errs}
+	}
 	return nil
 }
 
+// shutdownContext 返回关闭应用使用的 ctx，超时时间由 spring.shutdown.timeout 设置。
+func (app *App) shutdownContext() (context.Context, context.CancelFunc) {
+	ctx, cancel, err := shutdownContext(app.c.p.Get(SpringShutdownTimeout))
+	if err != nil {
+		app.logger.Error(err)
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	return ctx, cancel
+}
+
+// closeContainers 关闭已经开始刷新的容器，app 容器可能是 bootstrap 容器的子容器，
+// 需要先关闭。
+func (app *App) closeContainers(ctx context.Context) []error {
+	var errs []error
+	if app.c.state >= Refreshing {
+		errs = append(errs, app.c.closeContext(ctx)...)
+	}
+	if app.b != nil && app.b.c.state >= Refreshing {
+		errs = append(errs, app.b.c.closeContext(ctx)...)
+	}
+	return errs
+}
+
 func (app *App) clear() {
 	app.c.clear()
 	if app.b != nil {
@@ -185,7 +232,7 @@ func (app *App) start() error {
 		resourceLocator: new(defaultResourceLocator),
 	}
 
-	if err := e.prepare(); err != nil {
+	if err := app.step("prepare", e.prepare); err != nil {
 		return err
 	}
 
@@ -195,12 +242,24 @@ func (app *App) start() error {
 	}
 
 	if app.b != nil {
-		if err := app.b.start(e); err != nil {
+		// bootstrap 容器的刷新步骤记录在 bootstrap 步骤之下。
+		s := app.c.timeline.begin(nil, "bootstrap", "")
+		app.b.c.timeline = app.c.timeline
+		app.b.c.step = s
+		if app.b.asParent {
+			app.c.setParent(app.b.c)
+		}
+		err := app.b.start(e)
+		app.c.timeline.end(s)
+		if err != nil {
 			return err
 		}
 	}
 
-	if err := app.loadProperties(e); err != nil {
+	err := app.step("load-properties", func() error {
+		return app.loadProperties(e)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -209,27 +268,64 @@ func (app *App) start() error {
 		app.c.initProperties.Set(k, e.p.Get(k))
 	}
 
+	if app.overrides != nil {
+		for _, k := range app.overrides.Keys() {
+			app.c.initProperties.Set(k, app.overrides.Get(k))
+		}
+	}
+
+	// cond.OnResource 使用和配置文件相同的 ResourceLocator 链查找资源
+	app.c.locators = app.resourceLocators(e)
+
+	// 使用所有的全局自动配置模块
+	if !app.noModules {
+		for _, m := range modules {
+			app.c.Module(m)
+		}
+	}
+
 	if err := app.c.refresh(false); err != nil {
 		return err
 	}
 
+	// 按照阶段启动 Lifecycle 对象，Web 服务器所在的阶段除外。
+	err = app.step("lifecycle-start", func() error {
+		return app.startLifecycles(func(phase int) bool { return phase < WebServerPhase })
+	})
+	if err != nil {
+		return err
+	}
+
 	// 执行命令行启动器
+	s := app.c.timeline.begin(nil, "runners", "")
 	for _, r := range app.Runners {
 		r.Run(app.c)
 	}
+	app.c.timeline.end(s)
 
 	// 通知应用启动事件
+	s = app.c.timeline.begin(nil, "app-start", "")
 	for _, event := range app.Events {
 		event.OnAppStart(app.c)
 	}
+	app.c.timeline.end(s)
+
+	// 最后启动 Web 服务器所在阶段的 Lifecycle 对象，保证命令行启动器和应用启动
+	// 事件注册的路由和过滤器生效。
+	err = app.step("web-start", func() error {
+		return app.startLifecycles(func(phase int) bool { return phase >= WebServerPhase })
+	})
+	if err != nil {
+		return err
+	}
 
 	app.clear()
 
-	// 通知应用停止事件
-	app.c.Go(func(ctx context.Context) {
+	// 通知应用停止事件，事件接收的 ctx 在关闭超时后发出 Done 信号。
+	app.c.goNamed("app-stop-events", func(ctx context.Context) {
 		<-ctx.Done()
 		for _, event := range app.Events {
-			event.OnAppStop(context.Background())
+			event.OnAppStop(app.stopCtx)
 		}
 	})
 
@@ -237,6 +333,18 @@ func (app *App) start() error {
 	return nil
 }
 
+// step 执行启动阶段 fn 并将其耗时记录到启动时间线上。
+func (app *App) step(name string, fn func() error) error {
+	s := app.c.timeline.begin(nil, name, "")
+	defer app.c.timeline.end(s)
+	return fn()
+}
+
+// Timeline 返回应用的启动时间线。
+func (app *App) Timeline() *StartupTimeline {
+	return app.c.timeline
+}
+
 const DefaultBanner = `
                                               (_)              
   __ _    ___             ___   _ __    _ __   _   _ __     __ _ 
@@ -304,6 +412,26 @@ func (app *App) loadProperties(e *configuration) error {
 		resources = append(resources, sources...)
 	}
 
+	if err := app.readResources(resources); err != nil {
+		return err
+	}
+
+	// 配置文件中也可以定义 profile 组，但是环境变量和命令行中的定义优先。
+	groups := conf.New()
+	for _, p := range []*conf.Properties{app.c.initProperties, e.p} {
+		for _, k := range p.Keys() {
+			if strings.HasPrefix(k, SpringProfilesGroup+".") {
+				if err := groups.Set(k, p.Get(k)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if err := e.expandProfiles(groups); err != nil {
+		return err
+	}
+
+	resources = nil
 	for _, profile := range e.ActiveProfiles {
 		for _, ext := range e.ConfigExtensions {
 			sources, err := app.loadResource(e, "application-"+profile+ext)
@@ -313,7 +441,11 @@ func (app *App) loadProperties(e *configuration) error {
 			resources = append(resources, sources...)
 		}
 	}
+	return app.readResources(resources)
+}
 
+// readResources 读取配置文件中的属性。
+func (app *App) readResources(resources []Resource) error {
 	for _, resource := range resources {
 		b, err := ioutil.ReadAll(resource)
 		if err != nil {
@@ -332,33 +464,26 @@ func (app *App) loadProperties(e *configuration) error {
 }
 
 func (app *App) loadResource(e *configuration, filename string) ([]Resource, error) {
+	return locateResource(app.resourceLocators(e), filename)
+}
 
+// resourceLocators 返回查找资源的 ResourceLocator 链。
+func (app *App) resourceLocators(e *configuration) []ResourceLocator {
 	var locators []ResourceLocator
 	locators = append(locators, e.resourceLocator)
 	if app.b != nil {
 		locators = append(locators, app.b.resourceLocators...)
 	}
-
-	var resources []Resource
-	for _, locator := range locators {
-		sources, err := locator.Locate(filename)
-		if err != nil {
-			return nil, err
-		}
-		resources = append(resources, sources...)
-	}
-	return resources, nil
+	return locators
 }
 
-// ShutDown 关闭执行器
+// ShutDown 关闭执行器，可以在 Run 之前或者在任意 goroutine 中调用，只有第一
+// 次调用的 msg 会被记录。日志由 Run 打印，因为调用时日志可能还没有初始化。
 func (app *App) ShutDown(msg ...string) {
-	app.logger.Infof("program will exit %s", strings.Join(msg, " "))
-	select {
-	case <-app.exitChan:
-		// chan 已关闭，无需再次关闭。
-	default:
+	app.exitOnce.Do(func() {
+		app.exitMsg = strings.Join(msg, " ")
 		close(app.exitChan)
-	}
+	})
 }
 
 // Bootstrap 返回 *bootstrap 对象。
@@ -379,6 +504,15 @@ func (app *App) Property(key string, value interface{}) {
 	app.c.Property(key, value)
 }
 
+// OverrideProperty 设置优先级最高的属性，覆盖配置文件、环境变量以及命令行参数
+// 中的同名属性，通常用于测试。
+func (app *App) OverrideProperty(key string, value interface{}) {
+	if app.overrides == nil {
+		app.overrides = conf.New()
+	}
+	app.overrides.Set(key, value)
+}
+
 // Accept 参考 Container.Accept 的解释。
 func (app *App) Accept(b *BeanDefinition) *BeanDefinition {
 	return app.c.Accept(b)
@@ -394,6 +528,37 @@ func (app *App) Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition {
 	return app.c.Accept(NewBean(ctor, args...))
 }
 
+// RegisterScope 参考 Container.RegisterScope 的解释。
+func (app *App) RegisterScope(name string, scope Scope) {
+	app.c.RegisterScope(name, scope)
+}
+
+// Intercept 参考 Container.Intercept 的解释。
+func (app *App) Intercept(selector util.BeanSelector, fn Interceptor) {
+	app.c.Intercept(selector, fn)
+}
+
+// Listen 参考 Container.Listen 的解释。
+func (app *App) Listen(l *EventListener) *BeanDefinition {
+	return app.c.Listen(l)
+}
+
+// Module 参考 Container.Module 的解释。
+func (app *App) Module(m *Module) {
+	app.c.Module(m)
+}
+
+// IgnoreGlobalModules 不使用通过 RegisterModule 注册的全局自动配置模块，只使用
+// 通过 Module 方法添加的模块。
+func (app *App) IgnoreGlobalModules() {
+	app.noModules = true
+}
+
+// Schedule 参考 Container.Schedule 的解释。
+func (app *App) Schedule(spec string, fn interface{}, args ...arg.Arg) *ScheduledTask {
+	return app.c.Schedule(spec, fn, args...)
+}
+
 // HttpGet 注册 GET 方法处理函数。
 func (app *App) HttpGet(path string, h http.HandlerFunc) *web.Mapper {
 	return app.router.HttpGet(path, h)
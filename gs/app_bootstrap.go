@@ -24,17 +24,19 @@ import (
 )
 
 type tempBootstrap struct {
-	resourceLocators []ResourceLocator `autowire:""`
+	resourceLocators []ResourceLocator `autowire:"?"`
 }
 
 type bootstrap struct {
 	*tempBootstrap
-	c *container
+	c        *container
+	asParent bool
 }
 
 func newBootstrap() *bootstrap {
 	return &bootstrap{
-		c: New().(*container),
+		tempBootstrap: &tempBootstrap{},
+		c:             New().(*container),
 	}
 }
 
@@ -42,6 +44,13 @@ func (b *bootstrap) clear() {
 	b.tempBootstrap = nil
 }
 
+// AsParent 将 bootstrap 容器设置为 app 容器的父容器，app 容器中找不到的 bean 和
+// 属性从 bootstrap 容器中查找。默认情况下两个容器是相互独立的。
+func (b *bootstrap) AsParent() *bootstrap {
+	b.asParent = true
+	return b
+}
+
 // OnProperty 参考 App.OnProperty 的解释。
 func (b *bootstrap) OnProperty(key string, fn interface{}) {
 	b.c.OnProperty(key, fn)
@@ -69,7 +78,8 @@ func (b *bootstrap) ResourceLocator(i interface{}) *BeanDefinition {
 
 func (b *bootstrap) start(e *configuration) error {
 
-	b.c.Object(b)
+	// 嵌入的指针字段不会被注入，因此直接注册 tempBootstrap 对象。
+	b.c.Object(b.tempBootstrap)
 
 	if err := b.loadBootstrap(e); err != nil {
 		return err
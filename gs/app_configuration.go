@@ -33,6 +33,13 @@ const IncludeEnvPatterns = "INCLUDE_ENV_PATTERNS"
 // ExcludeEnvPatterns 排除符合条件的环境变量。
 const ExcludeEnvPatterns = "EXCLUDE_ENV_PATTERNS"
 
+// SpringProfilesActive 激活的 profile 列表。
+const SpringProfilesActive = "spring.profiles.active"
+
+// SpringProfilesGroup profile 组的属性前缀，例如 spring.profiles.group.prod=db-prod,mq-prod
+// 表示激活 prod 时同时激活 db-prod 和 mq-prod 。
+const SpringProfilesGroup = "spring.profiles.group"
+
 type configuration struct {
 	p *conf.Properties
 
@@ -117,5 +124,53 @@ func (e *configuration) prepare() error {
 	if err := e.p.Bind(e.resourceLocator); err != nil {
 		return err
 	}
-	return nil
+	return e.expandProfiles(e.p)
+}
+
+// expandProfiles 使用 p 中定义的 profile 组展开激活的 profile，组的成员紧跟在
+// 组的后面，组可以嵌套，重复的 profile 只保留第一个。展开后的列表同时保存到
+// spring.profiles.active 属性中，以便 cond.OnProfile 使用。
+func (e *configuration) expandProfiles(p *conf.Properties) error {
+
+	var (
+		profiles []string
+		expanded bool
+		expand   func(profile string) error
+	)
+
+	seen := make(map[string]bool)
+	expand = func(profile string) error {
+		if profile = strings.TrimSpace(profile); profile == "" || seen[profile] {
+			return nil
+		}
+		seen[profile] = true
+		profiles = append(profiles, profile)
+		key := SpringProfilesGroup + "." + profile
+		if !p.Has(key) {
+			return nil
+		}
+		var members []string
+		if err := p.Bind(&members, conf.Key(key)); err != nil {
+			return err
+		}
+		for _, m := range members {
+			expanded = true
+			if err := expand(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, profile := range e.ActiveProfiles {
+		if err := expand(profile); err != nil {
+			return err
+		}
+	}
+
+	if !expanded {
+		return nil
+	}
+	e.ActiveProfiles = profiles
+	return e.p.Set(SpringProfilesActive, strings.Join(profiles, ","))
 }
@@ -0,0 +1,213 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-core/conf"
+)
+
+// SpringLifecycleTimeout 每个阶段启动或者停止的超时时间，默认为 30s，可以通过
+// spring.lifecycle.phase.<phase>.timeout 为某个阶段单独设置。
+const SpringLifecycleTimeout = "spring.lifecycle.timeout"
+
+const defaultLifecycleTimeout = 30 * time.Second
+
+// Lifecycle 需要在应用启动后开始运行、在应用退出前停止运行的 bean，例如服务器、
+// 消息消费者、定时任务等。bean 需要导出 Lifecycle 接口，App 在容器刷新之后按照
+// Phase 从小到大的顺序启动它们，在应用退出时按照 Phase 从大到小的顺序停止它们。
+type Lifecycle interface {
+
+	// Start 启动 bean，ctx 在阶段超时后发出 Done 信号，长时间运行的任务应当在
+	// 其他的 goroutine 中执行。
+	Start(ctx context.Context) error
+
+	// Stop 停止 bean，ctx 在阶段超时后发出 Done 信号。
+	Stop(ctx context.Context) error
+
+	// Phase 返回 bean 所在的阶段，同一阶段的 bean 依次启动，同时停止。
+	Phase() int
+
+	// IsRunning 返回 bean 是否正在运行，只有未运行的 bean 才会启动，只有正在运
+	// 行的 bean 才会停止。
+	IsRunning() bool
+}
+
+// lifecyclePhase 是同一阶段的所有 Lifecycle 对象。
+type lifecyclePhase struct {
+	phase int
+	beans []Lifecycle
+}
+
+// groupLifecycles 按照 Phase 从小到大的顺序对 Lifecycle 对象进行分组。
+func groupLifecycles(beans []Lifecycle) []*lifecyclePhase {
+	m := make(map[int]*lifecyclePhase)
+	var phases []*lifecyclePhase
+	for _, l := range beans {
+		p, ok := m[l.Phase()]
+		if !ok {
+			p = &lifecyclePhase{phase: l.Phase()}
+			m[l.Phase()] = p
+			phases = append(phases, p)
+		}
+		p.beans = append(p.beans, l)
+	}
+	sort.Slice(phases, func(i, j int) bool {
+		return phases[i].phase < phases[j].phase
+	})
+	return phases
+}
+
+// phaseTimeout 返回阶段启动或者停止的超时时间。
+func (app *App) phaseTimeout(phase int) (time.Duration, error) {
+	key := "spring.lifecycle.phase." + strconv.Itoa(phase) + ".timeout"
+	s := app.c.p.Get(key, conf.Def(app.c.p.Get(SpringLifecycleTimeout)))
+	if s == "" {
+		return defaultLifecycleTimeout, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid lifecycle timeout %q in phase %d", s, phase)
+	}
+	return d, nil
+}
+
+// startLifecycles 按照 Phase 从小到大的顺序启动 accept 接受的阶段中的 Lifecycle
+// 对象，启动失败或者超时的时候停止已经启动的对象并返回 error 。
+func (app *App) startLifecycles(accept func(phase int) bool) error {
+	for _, p := range groupLifecycles(app.Lifecycles) {
+		if !accept(p.phase) {
+			continue
+		}
+		if err := app.startPhase(p); err != nil {
+			app.stopLifecycles(context.Background())
+			return err
+		}
+	}
+	return nil
+}
+
+func (app *App) startPhase(p *lifecyclePhase) error {
+
+	timeout, err := app.phaseTimeout(p.phase)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(app.c.ctx, timeout)
+	defer cancel()
+
+	for _, l := range p.beans {
+		if l.IsRunning() {
+			continue
+		}
+		done := make(chan error, 1)
+		go func(l Lifecycle) { done <- l.Start(ctx) }(l)
+		select {
+		case err = <-done:
+			if err != nil {
+				return fmt.Errorf("start %T in phase %d error: %w", l, p.phase, err)
+			}
+		case <-ctx.Done():
+			return fmt.Errorf("start %T in phase %d timeout after %v", l, p.phase, timeout)
+		}
+	}
+	return nil
+}
+
+// stopLifecycles 按照 Phase 从大到小的顺序停止正在运行的 Lifecycle 对象，同一
+// 阶段的对象同时停止，超时后不再等待。每个阶段的超时时间不会超过 ctx 的超时时
+// 间，返回停止失败以及没有在超时时间内停止的对象。
+func (app *App) stopLifecycles(ctx context.Context) []error {
+	var errs []error
+	phases := groupLifecycles(app.Lifecycles)
+	for i := len(phases) - 1; i >= 0; i-- {
+		errs = append(errs, app.stopPhase(ctx, phases[i])...)
+	}
+	return errs
+}
+
+func (app *App) stopPhase(ctx context.Context, p *lifecyclePhase) []error {
+
+	timeout, err := app.phaseTimeout(p.phase)
+	if err != nil {
+		app.logger.Error(err)
+		timeout = defaultLifecycleTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var (
+		mutex   sync.Mutex
+		wg      sync.WaitGroup
+		errs    []error
+		pending = make(map[Lifecycle]bool)
+	)
+
+	for _, l := range p.beans {
+		if !l.IsRunning() {
+			continue
+		}
+		mutex.Lock()
+		pending[l] = true
+		mutex.Unlock()
+		wg.Add(1)
+		go func(l Lifecycle) {
+			defer wg.Done()
+			err := l.Stop(ctx)
+			mutex.Lock()
+			defer mutex.Unlock()
+			// 超时之后才返回的 Lifecycle 对象也视为阻塞了关闭。
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				err = fmt.Errorf("stop %T in phase %d error: %w", l, p.phase, err)
+				app.logger.Error(err)
+				errs = append(errs, err)
+			}
+			delete(pending, l)
+		}(l)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	for l := range pending {
+		err = fmt.Errorf("stop %T in phase %d blocked shutdown", l, p.phase)
+		app.logger.Warn(err)
+		errs = append(errs, err)
+	}
+	return append([]error(nil), errs...)
+}
@@ -53,3 +53,76 @@ func (locator *defaultResourceLocator) Locate(filename string) ([]Resource, erro
 	}
 	return resources, nil
 }
+
+// Exists 返回是否存在名字为 filename 的资源，只检查文件状态而不打开文件。
+func (locator *defaultResourceLocator) Exists(filename string) (bool, error) {
+	for _, location := range locator.configLocations {
+		fileLocation := filepath.Join(location, filename)
+		_, err := os.Stat(fileLocation)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// resourceChecker 可以不打开资源就判断资源是否存在的 ResourceLocator 。
+type resourceChecker interface {
+	Exists(filename string) (bool, error)
+}
+
+// locateResource 依次使用 locators 查找名字为 filename 的资源。
+func locateResource(locators []ResourceLocator, filename string) ([]Resource, error) {
+	var resources []Resource
+	for _, locator := range locators {
+		sources, err := locator.Locate(filename)
+		if err != nil {
+			return nil, err
+		}
+		resources = append(resources, sources...)
+	}
+	return resources, nil
+}
+
+// HasResource 返回是否存在名字为 filename 的资源，实现了 cond.ResourceFinder 接口。
+// 没有设置 ResourceLocator 链时，例如不是通过 App 使用的容器，直接查找文件系统。
+func (c *container) HasResource(filename string) (bool, error) {
+
+	if len(c.locators) == 0 {
+		_, err := os.Stat(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+		return err == nil, nil
+	}
+
+	for _, locator := range c.locators {
+		found, err := hasResource(locator, filename)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// hasResource 返回 locator 是否能找到名字为 filename 的资源，优先使用
+// resourceChecker 检查，避免打开 socket 等特殊文件。
+func hasResource(locator ResourceLocator, filename string) (bool, error) {
+	if checker, ok := locator.(resourceChecker); ok {
+		return checker.Exists(filename)
+	}
+	resources, err := locator.Locate(filename)
+	if err != nil {
+		return false, err
+	}
+	for _, r := range resources {
+		if closer, ok := r.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return len(resources) > 0, nil
+}
@@ -17,12 +17,21 @@
 package gs_test
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
 	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-spring/spring-base/assert"
 	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/cond"
+	"github.com/go-spring/spring-core/gs/gstest"
 )
 
 func startApplication(cfgLocation string, fn func(gs.Context)) *gs.App {
@@ -81,3 +90,332 @@ func TestConfig(t *testing.T) {
 		defer app.ShutDown("run test end")
 	})
 }
+
+func TestApp_Timeline(t *testing.T) {
+	os.Clearenv()
+	app := startApplication("testdata/config/", func(ctx gs.Context) {})
+	defer app.ShutDown("run test end")
+	var names []string
+	for _, s := range app.Timeline().Steps() {
+		names = append(names, s.Name)
+	}
+	assert.Equal(t, names, []string{"prepare", "load-properties", "refresh", "lifecycle-start", "runners", "app-start", "web-start"})
+}
+
+func TestApp_ProfileGroups(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_PROFILES_ACTIVE", "prod")
+	props := make(chan [2]string, 1)
+	app := startApplication("testdata/profiles/", func(ctx gs.Context) {
+		props <- [2]string{ctx.Prop("spring.profiles.active"), ctx.Prop("db.url")}
+	})
+	defer app.ShutDown("run test end")
+	p := <-props
+	assert.Equal(t, p[0], "prod,db-prod,db-pool,mq-prod")
+	assert.Equal(t, p[1], "prod")
+}
+
+func TestApp_Bootstrap(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+	// 没有注册 ResourceLocator 的 bootstrap 容器也可以正常启动。
+	ctx := gstest.Run(t, gstest.Register(func(app *gs.App) {
+		app.Bootstrap().Property("region", "eu")
+	}))
+	assert.NotNil(t, ctx)
+}
+
+func TestApp_OnResource(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+	ctx := gstest.Run(t, gstest.Register(func(app *gs.App) {
+		app.Object(&BeanZero{1}).Name("found").On(cond.OnResource("logger.xml"))
+		app.Object(&BeanZero{2}).Name("missing").On(cond.OnResource("missing.xml"))
+	}))
+	var beans []*BeanZero
+	err := ctx.Get(&beans)
+	assert.Nil(t, err)
+	assert.Equal(t, len(beans), 1)
+	assert.Equal(t, beans[0].Int, 1)
+}
+
+func TestApp_OnResourceSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gs")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	l, err := net.Listen("unix", filepath.Join(dir, "app.sock"))
+	if err != nil {
+		t.Skip(err)
+	}
+	defer l.Close()
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", dir)
+	ctx := gstest.Run(t, gstest.Register(func(app *gs.App) {
+		app.Object(&BeanZero{1}).Name("socket").On(cond.OnResource("app.sock"))
+	}))
+	var beans []*BeanZero
+	err = ctx.Get(&beans)
+	assert.Nil(t, err)
+	assert.Equal(t, len(beans), 1)
+}
+
+func TestApp_StartError(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+
+	var closed bool
+	app := gs.NewApp()
+	app.Bootstrap().Object(&BeanZero{1}).Destroy(func(*BeanZero) { closed = true })
+	app.Provide(func() (*BeanZero, error) {
+		return nil, errors.New("start failed")
+	})
+	err := app.Run()
+	assert.Error(t, err, "start failed")
+	// 启动失败时关闭已经创建的 bootstrap 容器。
+	assert.True(t, closed)
+}
+
+type bootstrapRegion struct {
+	Region string `value:"${region:=none}"`
+}
+
+func TestApp_BootstrapParent(t *testing.T) {
+
+	t.Run("default", func(t *testing.T) {
+		os.Clearenv()
+		r := &bootstrapRegion{}
+		gstest.Run(t, gstest.Register(func(app *gs.App) {
+			app.Bootstrap().Property("region", "eu")
+			app.Object(r)
+		}))
+		assert.Equal(t, r.Region, "none")
+	})
+
+	t.Run("as parent", func(t *testing.T) {
+		os.Clearenv()
+		r := &bootstrapRegion{}
+		gstest.Run(t, gstest.Register(func(app *gs.App) {
+			app.Bootstrap().AsParent().Property("region", "eu")
+			app.Object(r)
+		}))
+		assert.Equal(t, r.Region, "eu")
+	})
+}
+
+type phaseRecorder struct {
+	mutex  sync.Mutex
+	events []string
+}
+
+func (r *phaseRecorder) add(event string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *phaseRecorder) get() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+type phaseBean struct {
+	name    string
+	phase   int
+	running bool
+	r       *phaseRecorder
+	block   bool
+}
+
+func (b *phaseBean) Start(ctx context.Context) error {
+	b.r.add("start " + b.name)
+	b.running = true
+	return nil
+}
+
+func (b *phaseBean) Stop(ctx context.Context) error {
+	b.r.add("stop " + b.name)
+	if b.block {
+		<-ctx.Done()
+	}
+	b.running = false
+	return nil
+}
+
+func (b *phaseBean) Phase() int {
+	return b.phase
+}
+
+func (b *phaseBean) IsRunning() bool {
+	return b.running
+}
+
+func TestApp_Lifecycle(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+	gs.Setenv("GS_SPRING_LIFECYCLE_PHASE_1_TIMEOUT", "50ms")
+
+	r := &phaseRecorder{}
+	app := gs.NewApp()
+	app.Object(&phaseBean{name: "server", phase: 2, r: r}).Name("server").Export((*gs.Lifecycle)(nil))
+	app.Object(&phaseBean{name: "consumer", phase: 1, r: r, block: true}).Name("consumer").Export((*gs.Lifecycle)(nil))
+	app.Object(&phaseBean{name: "db", phase: -1, r: r}).Name("db").Export((*gs.Lifecycle)(nil))
+
+	exited := runApp(app)
+	assert.Equal(t, r.get(), []string{"start db", "start consumer", "start server"})
+
+	app.ShutDown("run test end")
+	assert.Error(t, <-exited, "stop \\*gs_test.phaseBean in phase 1 blocked shutdown")
+	assert.Equal(t, r.get(), []string{
+		"start db", "start consumer", "start server",
+		"stop server", "stop consumer", "stop db",
+	})
+}
+
+type phaseRunner struct {
+	r *phaseRecorder
+}
+
+func (p *phaseRunner) Run(ctx gs.Context) { p.r.add("runner") }
+
+type phaseEvent struct {
+	r *phaseRecorder
+}
+
+func (e *phaseEvent) OnAppStart(ctx gs.Context) { e.r.add("event") }
+
+func (e *phaseEvent) OnAppStop(ctx context.Context) {}
+
+func TestApp_WebServerPhase(t *testing.T) {
+	os.Clearenv()
+	gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+
+	r := &phaseRecorder{}
+	app := gs.NewApp()
+	app.Object(&phaseBean{name: "web", phase: gs.WebServerPhase, r: r}).Name("web").Export((*gs.Lifecycle)(nil))
+	app.Object(&phaseBean{name: "db", phase: 1, r: r}).Name("db").Export((*gs.Lifecycle)(nil))
+	app.Object(&phaseRunner{r: r}).Export((*gs.AppRunner)(nil))
+	app.Object(&phaseEvent{r: r}).Export((*gs.AppEvent)(nil))
+
+	exited := runApp(app)
+	app.ShutDown("run test end")
+	assert.Nil(t, <-exited)
+	assert.Equal(t, r.get(), []string{
+		"start db", "runner", "event", "start web",
+		"stop web", "stop db",
+	})
+}
+
+type shutdownCache struct{}
+
+type shutdownDB struct {
+	Cache  *shutdownCache `autowire:""`
+	closed bool
+}
+
+type shutdownEvent struct {
+	deadline bool
+}
+
+func (e *shutdownEvent) OnAppStart(ctx gs.Context) {}
+
+func (e *shutdownEvent) OnAppStop(ctx context.Context) {
+	_, e.deadline = ctx.Deadline()
+}
+
+// readyEvent 在应用启动完成时发出信号。
+type readyEvent struct {
+	ready chan struct{}
+}
+
+func (e *readyEvent) OnAppStart(ctx gs.Context) { close(e.ready) }
+
+func (e *readyEvent) OnAppStop(ctx context.Context) {}
+
+// runApp 在 goroutine 中运行应用并等待其启动完成，返回接收 Run 结果的 chan 。
+func runApp(app *gs.App) chan error {
+	e := &readyEvent{ready: make(chan struct{})}
+	app.Object(e).Export((*gs.AppEvent)(nil))
+	exited := make(chan error, 1)
+	go func() { exited <- app.Run() }()
+	select {
+	case <-e.ready:
+	case err := <-exited:
+		exited <- err
+	}
+	return exited
+}
+
+// runShutdown 启动应用然后关闭，返回关闭过程中发生的错误。
+func runShutdown(t *testing.T, app *gs.App, n int) []string {
+	exited := runApp(app)
+	app.ShutDown("run test end")
+	var e *gs.ShutdownError
+	if err := <-exited; !errors.As(err, &e) {
+		t.Fatalf("expect shutdown error but got %v", err)
+	}
+	var msgs []string
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	if len(msgs) != n {
+		t.Fatalf("expect %d shutdown errors but got %q", n, msgs)
+	}
+	return msgs
+}
+
+func TestApp_Shutdown(t *testing.T) {
+
+	t.Run("destroy", func(t *testing.T) {
+		os.Clearenv()
+		gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+		gs.Setenv("GS_SPRING_SHUTDOWN_TIMEOUT", "100ms")
+
+		app := gs.NewApp()
+		db := &shutdownDB{}
+		app.Object(db).Destroy(func(db *shutdownDB, ctx context.Context) error {
+			db.closed = true
+			return errors.New("db close error")
+		})
+		app.Object(&shutdownCache{}).Destroy(func(c *shutdownCache, ctx context.Context) {
+			<-ctx.Done()
+		})
+		event := &shutdownEvent{}
+		app.Object(event).Export((*gs.AppEvent)(nil))
+
+		msgs := runShutdown(t, app, 2)
+		assert.Matches(t, msgs[0], "destroy .*shutdownDB.* error: db close error")
+		assert.Matches(t, msgs[1], "destroy .*shutdownCache.* blocked shutdown")
+		assert.True(t, db.closed)
+		assert.True(t, event.deadline)
+	})
+
+	t.Run("goroutine", func(t *testing.T) {
+		os.Clearenv()
+		gs.Setenv("GS_SPRING_CONFIG_LOCATIONS", "testdata/config/")
+		gs.Setenv("GS_SPRING_SHUTDOWN_TIMEOUT", "100ms")
+
+		gs.Setenv("GS_SPRING_SHUTDOWN_GRACE_PERIOD", "50ms")
+
+		// 关闭超时之后剩下的销毁函数仍然执行，每个只有 spring.shutdown.grace.period 的执行时间。
+		var destroyed int32
+		app := gs.NewApp()
+		app.Object(&shutdownCache{}).Destroy(func(c *shutdownCache, ctx context.Context) {
+			<-ctx.Done()
+		})
+		app.Provide(func(ctx gs.Context) *shutdownDB {
+			ctx.Go(func(ctx context.Context) {
+				<-ctx.Done()
+				time.Sleep(time.Second)
+			})
+			return &shutdownDB{}
+		}).Destroy(func(db *shutdownDB) {
+			atomic.AddInt32(&destroyed, 1)
+		})
+
+		msgs := runShutdown(t, app, 2)
+		assert.Matches(t, msgs[0], "goroutine .*TestApp_Shutdown.* blocked shutdown")
+		assert.Matches(t, msgs[1], "destroy .*shutdownCache.* blocked shutdown")
+		assert.Equal(t, atomic.LoadInt32(&destroyed), int32(1))
+	})
+}
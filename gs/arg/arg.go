@@ -24,10 +24,12 @@ import (
 	"fmt"
 	"reflect"
 	"runtime"
+	"strings"
 
 	"github.com/go-spring/spring-base/code"
 	"github.com/go-spring/spring-base/log"
 	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/conf"
 	"github.com/go-spring/spring-core/gs/cond"
 )
 
@@ -42,6 +44,46 @@ type Context interface {
 	Wire(v reflect.Value, tag string) error
 }
 
+// PropertyChecker is an optional interface of Context, which is used to check
+// whether the optional properties of params struct exist.
+type PropertyChecker interface {
+	// Has returns whether the IoC container has a property.
+	Has(key string) bool
+}
+
+// In 嵌入到结构体中表示该结构体是参数结构体。参数类型为参数结构体时，容器按照字段的
+// value 标签绑定属性、按照 autowire 或者 inject 标签注入 bean，没有标签的字段和
+// 未导出的字段保持零值。设置了 optional:"true" 标签的字段是可选的，找不到 bean 或
+// 者属性不存在时保持零值。例如:
+//
+//	type ServerParams struct {
+//		arg.In
+//		Port   int       `value:"${server.port}"`
+//		Logger *Logger   `autowire:""`
+//		Tracer Tracer    `autowire:"" optional:"true"`
+//		Addrs  []string  `value:"${server.addrs}" optional:"true"`
+//	}
+//
+//	func NewServer(p ServerParams) *Server
+//
+// 参数结构体使构造函数不再依赖参数的位置，因此不需要 R0、R1 等带有下标的参数绑定。
+type In struct{}
+
+var inType = reflect.TypeOf(In{})
+
+// IsParams returns whether t is a params struct, which embeds In.
+func IsParams(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous && f.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
 // Arg 用于为函数参数提供绑定值。可以是 bean.Selector 类型，表示注入 bean ；
 // 可以是 ${X:=Y} 形式的字符串，表示属性绑定或者注入 bean ；可以是 ValueArg
 // 类型，表示不从 IoC 容器获取而是用户传入的普通值；可以是 IndexArg 类型，表示
@@ -176,17 +218,15 @@ func newArgList(fnType reflect.Type, args []Arg) (*argList, error) {
 		}
 	}
 
-	return &argList{fnType: fnType, args: fnArgs}, nil
+	// 在创建时获取 logger，因为定时任务等场景会并发调用同一个 Callable 。
+	r := &argList{fnType: fnType, args: fnArgs}
+	r.logger = log.GetLogger(util.TypeName(r))
+	return r, nil
 }
 
 // get returns all processed Args value. fileLine is the binding position of Callable.
 func (r *argList) get(ctx Context, fileLine string) ([]reflect.Value, error) {
 
-	// TODO 也许可以通过参数传递 *log.Logger 对象
-	if r.logger == nil {
-		r.logger = log.GetLogger(util.TypeName(r))
-	}
-
 	fnType := r.fnType
 	numIn := fnType.NumIn()
 	variadic := fnType.IsVariadic()
@@ -255,6 +295,19 @@ func (r *argList) getArg(ctx Context, arg Arg, t reflect.Type, fileLine string)
 		tag = util.TypeName(g) + ":"
 	}
 
+	// fills the params struct field by field.
+	if IsParams(t) {
+		if tag != "" {
+			err = util.Errorf(code.FileLine(), "params struct %s should not have arg %q", t, tag)
+			return reflect.Value{}, err
+		}
+		var v reflect.Value
+		if v, err = r.getParams(ctx, t); err != nil {
+			return reflect.Value{}, err
+		}
+		return v, nil
+	}
+
 	// binds properties value by the "value" tag.
 	if util.IsValueType(t) {
 		if tag == "" {
@@ -279,6 +332,92 @@ func (r *argList) getArg(ctx Context, arg Arg, t reflect.Type, fileLine string)
 	return reflect.Value{}, util.Errorf(code.FileLine(), "error type %s", t.String())
 }
 
+// getParams 按照字段的标签创建参数结构体。
+func (r *argList) getParams(ctx Context, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	if err := r.bindParams(ctx, v, t); err != nil {
+		return reflect.Value{}, err
+	}
+	return v, nil
+}
+
+// bindParams 按照字段的标签为参数结构体的字段赋值，和 bean 字段注入一样会进入
+// 没有标签的嵌入结构体。
+func (r *argList) bindParams(ctx Context, v reflect.Value, t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+		if f.Anonymous && f.Type == inType {
+			continue
+		}
+		if f.PkgPath != "" && !(f.Anonymous && f.Type.Kind() == reflect.Struct) {
+			continue
+		}
+
+		optional := f.Tag.Get("optional") == "true"
+		fv := v.Field(i)
+
+		if tag, ok := f.Tag.Lookup("value"); ok {
+			if optional {
+				// 属性不存在并且没有默认值时保持零值。
+				if t, err := conf.ParseTag(tag); err == nil && !t.HasDef && !hasProperty(ctx, fv, tag, t.Key) {
+					continue
+				}
+			}
+			if err := ctx.Bind(fv, tag); err != nil {
+				return util.Wrapf(err, code.FileLine(), "bind field %s error", f.Name)
+			}
+			continue
+		}
+
+		tag, ok := f.Tag.Lookup("autowire")
+		if !ok {
+			tag, ok = f.Tag.Lookup("inject")
+		}
+		if !ok {
+			if f.Anonymous && f.Type.Kind() == reflect.Struct {
+				if err := r.bindParams(ctx, fv, f.Type); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if strings.HasSuffix(tag, ",lazy") {
+			return util.Errorf(code.FileLine(), "lazy is not supported in params struct field %s", f.Name)
+		}
+		if optional {
+			tag = nullableTag(tag)
+		}
+		if err := ctx.Wire(fv, tag); err != nil {
+			return util.Wrapf(err, code.FileLine(), "wire field %s error", f.Name)
+		}
+	}
+	return nil
+}
+
+// hasProperty 返回属性是否存在，ctx 没有实现 PropertyChecker 接口时尝试绑定属性，
+// 绑定失败视为属性不存在。
+func hasProperty(ctx Context, v reflect.Value, tag string, key string) bool {
+	if c, ok := ctx.(PropertyChecker); ok {
+		return c.Has(key)
+	}
+	return ctx.Bind(reflect.New(v.Type()).Elem(), tag) == nil
+}
+
+// nullableTag 将 autowire 标签中的每个选择器都设置为允许为空。
+func nullableTag(tag string) string {
+	if tag == "" {
+		return "?"
+	}
+	ss := strings.Split(tag, ",")
+	for i, s := range ss {
+		if !strings.HasSuffix(s, "?") {
+			ss[i] = s + "?"
+		}
+	}
+	return strings.Join(ss, ",")
+}
+
 // optionArg Option 函数的参数绑定。
 type optionArg struct {
 	logger *log.Logger
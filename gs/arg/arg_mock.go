@@ -77,6 +77,43 @@ func (mr *MockContextMockRecorder) Wire(v, tag interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Wire", reflect.TypeOf((*MockContext)(nil).Wire), v, tag)
 }
 
+// MockPropertyChecker is a mock of PropertyChecker interface.
+type MockPropertyChecker struct {
+	ctrl     *gomock.Controller
+	recorder *MockPropertyCheckerMockRecorder
+}
+
+// MockPropertyCheckerMockRecorder is the mock recorder for MockPropertyChecker.
+type MockPropertyCheckerMockRecorder struct {
+	mock *MockPropertyChecker
+}
+
+// NewMockPropertyChecker creates a new mock instance.
+func NewMockPropertyChecker(ctrl *gomock.Controller) *MockPropertyChecker {
+	mock := &MockPropertyChecker{ctrl: ctrl}
+	mock.recorder = &MockPropertyCheckerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockPropertyChecker) EXPECT() *MockPropertyCheckerMockRecorder {
+	return m.recorder
+}
+
+// Has mocks base method.
+func (m *MockPropertyChecker) Has(key string) bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Has", key)
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// Has indicates an expected call of Has.
+func (mr *MockPropertyCheckerMockRecorder) Has(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Has", reflect.TypeOf((*MockPropertyChecker)(nil).Has), key)
+}
+
 // MockArg is a mock of Arg interface.
 type MockArg struct {
 	ctrl     *gomock.Controller
@@ -17,6 +17,7 @@
 package arg_test
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 
@@ -45,6 +46,12 @@ func init() {
 	util.Panic(err).When(err != nil)
 }
 
+// propertyContext 是实现了 PropertyChecker 接口的 Context 。
+type propertyContext struct {
+	*arg.MockContext
+	*arg.MockPropertyChecker
+}
+
 func TestBind(t *testing.T) {
 
 	t.Run("zero argument", func(t *testing.T) {
@@ -167,4 +174,149 @@ func TestBind(t *testing.T) {
 		assert.Equal(t, len(values), 0)
 	})
 
+	t.Run("params struct argument", func(t *testing.T) {
+		type st struct {
+			i int
+		}
+		type params struct {
+			arg.In
+			Port  int `value:"${port}"`
+			Bean  *st `autowire:"a"`
+			Addr  int `value:"${addr}" optional:"true"`
+			Cache *st `autowire:"" optional:"true"`
+			Name  string
+		}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := arg.NewMockContext(ctrl)
+		ctx.EXPECT().Bind(gomock.Any(), "${port}").DoAndReturn(func(v, tag interface{}) error {
+			v.(reflect.Value).SetInt(8080)
+			return nil
+		})
+		ctx.EXPECT().Wire(gomock.Any(), "a").DoAndReturn(func(v, tag interface{}) error {
+			v.(reflect.Value).Set(reflect.ValueOf(&st{3}))
+			return nil
+		})
+		ctx.EXPECT().Wire(gomock.Any(), "?").Return(nil)
+		checker := arg.NewMockPropertyChecker(ctrl)
+		checker.EXPECT().Has("addr").Return(false)
+		var p params
+		fn := func(v params) {
+			p = v
+		}
+		c, err := arg.Bind(fn, []arg.Arg{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Call(&propertyContext{ctx, checker})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, p.Port, 8080)
+		assert.Equal(t, p.Bean.i, 3)
+		assert.Equal(t, p.Addr, 0)
+		assert.Nil(t, p.Cache)
+		assert.Equal(t, p.Name, "")
+	})
+
+	t.Run("params struct without property checker", func(t *testing.T) {
+		type params struct {
+			arg.In
+			Addr int `value:"${addr}" optional:"true"`
+		}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := arg.NewMockContext(ctrl)
+		// 没有实现 PropertyChecker 接口时绑定失败的可选属性保持零值。
+		ctx.EXPECT().Bind(gomock.Any(), "${addr}").Return(errors.New("property addr not exist"))
+		var p params
+		fn := func(v params) {
+			p = v
+		}
+		c, err := arg.Bind(fn, []arg.Arg{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Call(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, p.Addr, 0)
+	})
+
+	t.Run("params struct embedded struct", func(t *testing.T) {
+		type st struct {
+			i int
+		}
+		type Common struct {
+			Port int `value:"${port}"`
+			Bean *st `autowire:"a"`
+		}
+		type params struct {
+			arg.In
+			Common
+		}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := arg.NewMockContext(ctrl)
+		ctx.EXPECT().Bind(gomock.Any(), "${port}").DoAndReturn(func(v, tag interface{}) error {
+			v.(reflect.Value).SetInt(8080)
+			return nil
+		})
+		ctx.EXPECT().Wire(gomock.Any(), "a").DoAndReturn(func(v, tag interface{}) error {
+			v.(reflect.Value).Set(reflect.ValueOf(&st{3}))
+			return nil
+		})
+		var p params
+		fn := func(v params) {
+			p = v
+		}
+		c, err := arg.Bind(fn, []arg.Arg{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Call(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, p.Port, 8080)
+		assert.Equal(t, p.Bean.i, 3)
+	})
+
+	t.Run("params struct lazy field", func(t *testing.T) {
+		type st struct {
+			i int
+		}
+		type params struct {
+			arg.In
+			Bean *st `autowire:"a,lazy" optional:"true"`
+		}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := arg.NewMockContext(ctrl)
+		fn := func(v params) {}
+		c, err := arg.Bind(fn, []arg.Arg{}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Call(ctx)
+		assert.Error(t, err, "lazy is not supported in params struct field Bean")
+	})
+
+	t.Run("params struct with arg", func(t *testing.T) {
+		type params struct {
+			arg.In
+		}
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := arg.NewMockContext(ctrl)
+		fn := func(v params) {}
+		c, err := arg.Bind(fn, []arg.Arg{"a"}, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Call(ctx)
+		assert.Error(t, err, "params struct .* should not have arg \"a\"")
+	})
+
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/grpc"
 	"github.com/go-spring/spring-core/gs/arg"
+	"github.com/go-spring/spring-core/gs/cond"
 	"github.com/go-spring/spring-core/web"
 )
 
@@ -45,7 +46,9 @@ func Web(enable bool) *startup {
 
 func (s *startup) Run() error {
 	if s.web {
-		Object(new(WebStarter)).Export((*AppEvent)(nil))
+		lifecycle := cond.OnProperty(SpringWebLifecycle, cond.HavingValue("true"))
+		Object(new(WebStarter)).Export((*AppEvent)(nil)).On(cond.Not(lifecycle))
+		Object(new(WebStarter)).Export((*Lifecycle)(nil)).On(lifecycle)
 	}
 	return app.Run()
 }
@@ -95,6 +98,26 @@ func Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition {
 	return app.c.Accept(NewBean(ctor, args...))
 }
 
+// RegisterScope 参考 App.RegisterScope 的解释。
+func RegisterScope(name string, scope Scope) {
+	app.RegisterScope(name, scope)
+}
+
+// Intercept 参考 App.Intercept 的解释。
+func Intercept(selector util.BeanSelector, fn Interceptor) {
+	app.Intercept(selector, fn)
+}
+
+// Listen 参考 App.Listen 的解释。
+func Listen(l *EventListener) *BeanDefinition {
+	return app.Listen(l)
+}
+
+// Schedule 参考 App.Schedule 的解释。
+func Schedule(spec string, fn interface{}, args ...arg.Arg) *ScheduledTask {
+	return app.Schedule(spec, fn, args...)
+}
+
 // HttpGet 参考 App.HttpGet 的解释。
 func HttpGet(path string, h http.HandlerFunc) *web.Mapper {
 	return app.HttpGet(path, h)
@@ -18,22 +18,48 @@ package gs
 
 import (
 	"context"
+	"math"
 	"net/http"
 	"strings"
+	"sync"
 
+	"github.com/go-spring/spring-base/util"
 	"github.com/go-spring/spring-core/web"
 )
 
-// WebStarter Web 服务器启动器
+// SpringWebLifecycle 为 true 时 WebStarter 导出为 Lifecycle 对象，否则导出为
+// AppEvent 对象。
+const SpringWebLifecycle = "spring.web.lifecycle"
+
+// WebServerPhase Web 服务器所在的阶段，该阶段的 Lifecycle 对象在命令行启动器和
+// AppEvent.OnAppStart 之后启动，在其他 Lifecycle 对象之前停止。
+const WebServerPhase = math.MaxInt32
+
+// WebStarter Web 服务器启动器。WebStarter 默认导出为 AppEvent 对象，设置
+// spring.web.lifecycle=true 时导出为 Lifecycle 对象，两种方式下 Web 服务器都在
+// 命令行启动器和其他 AppEvent.OnAppStart 之后开始接收请求。
 type WebStarter struct {
 	Containers []web.Server `autowire:""`
 	Filters    []web.Filter `autowire:"${web.server.filters:=*?}"`
 	Router     web.Router   `autowire:""`
+	running    bool
+	wg         sync.WaitGroup
 }
 
-// OnAppStart 应用程序启动事件。
-func (starter *WebStarter) OnAppStart(ctx Context) {
+// Phase 返回 Web 服务器所在的阶段。
+func (starter *WebStarter) Phase() int {
+	return WebServerPhase
+}
+
+// IsRunning 返回 Web 服务器是否正在运行。
+func (starter *WebStarter) IsRunning() bool {
+	return starter.running
+}
+
+// Start 添加过滤器和路由然后启动 Web 服务器。
+func (starter *WebStarter) Start(ctx context.Context) error {
 	for _, c := range starter.Containers {
+		c.AddFilter(RequestScopeFilter())
 		c.AddFilter(starter.Filters...)
 	}
 	for _, m := range starter.Router.Mappers() {
@@ -41,7 +67,9 @@ func (starter *WebStarter) OnAppStart(ctx Context) {
 			c.AddMapper(m)
 		}
 	}
-	starter.startContainers(ctx)
+	starter.startContainers()
+	starter.running = true
+	return nil
 }
 
 func (starter *WebStarter) getContainers(m *web.Mapper) []web.Server {
@@ -54,20 +82,55 @@ func (starter *WebStarter) getContainers(m *web.Mapper) []web.Server {
 	return ret
 }
 
-func (starter *WebStarter) startContainers(ctx Context) {
+func (starter *WebStarter) startContainers() {
 	for i := range starter.Containers {
 		c := starter.Containers[i]
-		ctx.Go(func(_ context.Context) {
+		starter.wg.Add(1)
+		go func() {
+			defer starter.wg.Done()
 			if err := c.Start(); err != nil && err != http.ErrServerClosed {
 				ShutDown(err.Error())
 			}
-		})
+		}()
 	}
 }
 
+// Stop 优雅地关闭 Web 服务器，然后等待启动 Web 服务器的 goroutine 退出。
+func (starter *WebStarter) Stop(ctx context.Context) error {
+	var err error
+	for _, c := range starter.Containers {
+		if e := c.Stop(ctx); e != nil && err == nil {
+			err = e
+		}
+	}
+	starter.wg.Wait()
+	starter.running = false
+	return err
+}
+
+// OnAppStart 应用程序启动事件。
+func (starter *WebStarter) OnAppStart(ctx Context) {
+	if starter.running {
+		return
+	}
+	_ = starter.Start(ctx.Context())
+}
+
 // OnAppStop 应用程序结束事件。
 func (starter *WebStarter) OnAppStop(ctx context.Context) {
-	for _, c := range starter.Containers {
-		_ = c.Stop(ctx)
+	if !starter.running {
+		return
 	}
+	_ = starter.Stop(ctx)
+}
+
+// RequestScopeFilter 为每个 HTTP 请求开启 request 作用域，并在请求结束时销毁
+// 请求内创建的 bean 实例。
+func RequestScopeFilter() web.Filter {
+	return web.FuncFilter(func(ctx web.Context, chain web.FilterChain) {
+		end, err := beginRequest(ctx.Context())
+		util.Panic(err).When(err != nil)
+		defer end()
+		chain.Next(ctx, web.Recursive)
+	})
 }
@@ -52,6 +52,95 @@ func (c FuncCond) Matches(ctx Context) (bool, error) {
 	return c(ctx)
 }
 
+// Result is the evaluation result of a Condition, it records the values or
+// bean selectors involved and the results of sub-conditions that have been
+// evaluated, so it can be used to explain why a bean is valid or not.
+type Result struct {
+	Condition string    `json:"condition"`
+	Matched   bool      `json:"matched"`
+	Detail    string    `json:"detail,omitempty"`
+	Children  []*Result `json:"children,omitempty"`
+}
+
+// String returns the result as an indented tree.
+func (r *Result) String() string {
+	var buf strings.Builder
+	r.write(&buf, "")
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func (r *Result) write(buf *strings.Builder, indent string) {
+	matched := "unmatched"
+	if r.Matched {
+		matched = "matched"
+	}
+	buf.WriteString(indent + r.Condition + " " + matched)
+	if r.Detail != "" {
+		buf.WriteString(", " + r.Detail)
+	}
+	buf.WriteString("\n")
+	for _, c := range r.Children {
+		c.write(buf, indent+"  ")
+	}
+}
+
+// evaluator is implemented by conditions that can explain their evaluation.
+type evaluator interface {
+	evaluate(ctx Context) (*Result, error)
+}
+
+// Evaluate evaluates a Condition and returns its Result, the sub-conditions
+// skipped by short-circuit evaluation are not included in the Result.
+func Evaluate(c Condition, ctx Context) (*Result, error) {
+	if e, ok := c.(evaluator); ok {
+		return e.evaluate(ctx)
+	}
+	ok, err := c.Matches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := "OnMatches"
+	if _, isFunc := c.(FuncCond); !isFunc {
+		name = fmt.Sprintf("%T", c)
+	}
+	return &Result{Condition: name, Matched: ok}, nil
+}
+
+func matches(e evaluator, ctx Context) (bool, error) {
+	r, err := e.evaluate(ctx)
+	if err != nil {
+		return false, err
+	}
+	return r.Matched, nil
+}
+
+// evalFunc evaluates the sub-conditions of composite conditions, Matches only
+// needs the result of each sub-condition while Evaluate needs the details.
+type evalFunc func(c Condition) (*Result, error)
+
+func matchOnly(ctx Context) evalFunc {
+	return func(c Condition) (*Result, error) {
+		ok, err := c.Matches(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Matched: ok}, nil
+	}
+}
+
+func evaluateAll(ctx Context) evalFunc {
+	return func(c Condition) (*Result, error) {
+		return Evaluate(c, ctx)
+	}
+}
+
+func resultMatched(r *Result, err error) (bool, error) {
+	if err != nil {
+		return false, err
+	}
+	return r.Matched, nil
+}
+
 // OK returns a Condition that always returns true.
 func OK() Condition {
 	return FuncCond(func(ctx Context) (bool, error) {
@@ -70,28 +159,80 @@ func Not(c Condition) Condition {
 }
 
 func (c *not) Matches(ctx Context) (bool, error) {
-	ok, err := c.c.Matches(ctx)
-	return !ok, err
+	return resultMatched(c.eval(matchOnly(ctx)))
+}
+
+func (c *not) evaluate(ctx Context) (*Result, error) {
+	return c.eval(evaluateAll(ctx))
+}
+
+func (c *not) eval(fn evalFunc) (*Result, error) {
+	r, err := fn(c.c)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Condition: "Not", Matched: !r.Matched, Children: []*Result{r}}, nil
 }
 
 // onProperty is a Condition that checks a property and its value.
 type onProperty struct {
 	name           string
 	havingValue    string
+	matcher        *valueMatcher
 	matchIfMissing bool
 }
 
 func (c *onProperty) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onProperty) evaluate(ctx Context) (*Result, error) {
+
+	r := &Result{Condition: fmt.Sprintf("OnProperty(name=%s", c.name)}
+	if c.havingValue != "" {
+		r.Condition += fmt.Sprintf(", havingValue=%s", c.havingValue)
+	}
+	if c.matcher != nil {
+		r.Condition += ", " + c.matcher.desc
+	}
+	if c.matchIfMissing {
+		r.Condition += ", matchIfMissing"
+	}
+	r.Condition += ")"
 
 	if !ctx.Has(c.name) {
-		return c.matchIfMissing, nil
+		r.Matched = c.matchIfMissing
+		r.Detail = fmt.Sprintf("%s is missing", c.name)
+		return r, nil
+	}
+
+	if c.matcher != nil {
+		detail, ok, err := c.matcher.match(ctx, c.name)
+		if err != nil {
+			return nil, err
+		}
+		r.Matched, r.Detail = ok, detail
+		return r, nil
 	}
 
 	if c.havingValue == "" {
-		return true, nil
+		r.Matched = true
+		r.Detail = fmt.Sprintf("%s exists", c.name)
+		return r, nil
 	}
 
 	val := ctx.Prop(c.name)
+	r.Detail = fmt.Sprintf("%s=%q", c.name, val)
+	ok, err := c.matchValue(val)
+	if err != nil {
+		return nil, err
+	}
+	r.Matched = ok
+	return r, nil
+}
+
+func (c *onProperty) matchValue(val string) (bool, error) {
+
 	if !strings.HasPrefix(c.havingValue, "go:") {
 		return val == c.havingValue, nil
 	}
@@ -123,6 +264,34 @@ func (c *onMissingProperty) Matches(ctx Context) (bool, error) {
 	return !ctx.Has(c.name), nil
 }
 
+func (c *onMissingProperty) evaluate(ctx Context) (*Result, error) {
+	r := &Result{Condition: fmt.Sprintf("OnMissingProperty(name=%s)", c.name)}
+	if r.Matched = !ctx.Has(c.name); r.Matched {
+		r.Detail = fmt.Sprintf("%s is missing", c.name)
+	} else {
+		r.Detail = fmt.Sprintf("%s=%q", c.name, ctx.Prop(c.name))
+	}
+	return r, nil
+}
+
+// evaluateBean returns the Result of a bean condition, and the detail lists
+// the IDs of beans found by the selector.
+func evaluateBean(ctx Context, name string, selector util.BeanSelector, fn func(n int) bool) (*Result, error) {
+	beans, err := ctx.Find(selector)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, b := range beans {
+		ids = append(ids, b.ID())
+	}
+	return &Result{
+		Condition: fmt.Sprintf("%s(selector=%v)", name, selector),
+		Matched:   fn(len(beans)),
+		Detail:    fmt.Sprintf("found %d beans %v", len(beans), ids),
+	}, nil
+}
+
 // onBean is a Condition that returns true when finding more than one beans.
 type onBean struct {
 	selector util.BeanSelector
@@ -133,6 +302,10 @@ func (c *onBean) Matches(ctx Context) (bool, error) {
 	return len(beans) > 0, err
 }
 
+func (c *onBean) evaluate(ctx Context) (*Result, error) {
+	return evaluateBean(ctx, "OnBean", c.selector, func(n int) bool { return n > 0 })
+}
+
 // onMissingBean is a Condition that returns true when finding no beans.
 type onMissingBean struct {
 	selector util.BeanSelector
@@ -143,6 +316,10 @@ func (c *onMissingBean) Matches(ctx Context) (bool, error) {
 	return len(beans) == 0, err
 }
 
+func (c *onMissingBean) evaluate(ctx Context) (*Result, error) {
+	return evaluateBean(ctx, "OnMissingBean", c.selector, func(n int) bool { return n == 0 })
+}
+
 // onSingleBean is a Condition that returns true when finding only one bean.
 type onSingleBean struct {
 	selector util.BeanSelector
@@ -153,13 +330,8 @@ func (c *onSingleBean) Matches(ctx Context) (bool, error) {
 	return len(beans) == 1, err
 }
 
-// onExpression is a Condition that returns true when an expression returns true.
-type onExpression struct {
-	expression string
-}
-
-func (c *onExpression) Matches(ctx Context) (bool, error) {
-	return false, util.UnimplementedMethod
+func (c *onSingleBean) evaluate(ctx Context) (*Result, error) {
+	return evaluateBean(ctx, "OnSingleBean", c.selector, func(n int) bool { return n == 1 })
 }
 
 // Operator defines operation between conditions, including Or、And、None.
@@ -183,42 +355,50 @@ func Group(op Operator, cond ...Condition) Condition {
 }
 
 func (g *group) Matches(ctx Context) (bool, error) {
+	return resultMatched(g.eval(matchOnly(ctx)))
+}
+
+func (g *group) evaluate(ctx Context) (*Result, error) {
+	return g.eval(evaluateAll(ctx))
+}
+
+func (g *group) eval(fn evalFunc) (*Result, error) {
 
 	if len(g.cond) == 0 {
-		return false, errors.New("no condition in group")
+		return nil, errors.New("no condition in group")
 	}
 
+	r := &Result{}
 	switch g.op {
 	case Or:
-		for _, c := range g.cond {
-			if ok, err := c.Matches(ctx); err != nil {
-				return false, err
-			} else if ok {
-				return true, nil
-			}
-		}
-		return false, nil
+		r.Condition = "Group(Or)"
 	case And:
-		for _, c := range g.cond {
-			if ok, err := c.Matches(ctx); err != nil {
-				return false, err
-			} else if !ok {
-				return false, nil
-			}
-		}
-		return true, nil
+		r.Condition = "Group(And)"
 	case None:
-		for _, c := range g.cond {
-			if ok, err := c.Matches(ctx); err != nil {
-				return false, err
-			} else if ok {
-				return false, nil
-			}
+		r.Condition = "Group(None)"
+	default:
+		return nil, fmt.Errorf("error condition operator %d", g.op)
+	}
+
+	for _, c := range g.cond {
+		cr, err := fn(c)
+		if err != nil {
+			return nil, err
+		}
+		r.Children = append(r.Children, cr)
+		switch {
+		case g.op == Or && cr.Matched:
+			r.Matched = true
+			return r, nil
+		case g.op == And && !cr.Matched:
+			return r, nil
+		case g.op == None && cr.Matched:
+			return r, nil
 		}
-		return true, nil
 	}
 
-	return false, fmt.Errorf("error condition operator %d", g.op)
+	r.Matched = g.op != Or
+	return r, nil
 }
 
 // node is a Condition implemented by link of Condition(s).
@@ -229,38 +409,51 @@ type node struct {
 }
 
 func (n *node) Matches(ctx Context) (bool, error) {
+	return resultMatched(n.eval(matchOnly(ctx), nil))
+}
+
+// eval evaluates the linked conditions from n, the results of them are
+// appended to r's children.
+func (n *node) eval(fn evalFunc, r *Result) (*Result, error) {
+
+	if r == nil {
+		r = &Result{}
+	}
 
 	if n.cond == nil {
-		return true, nil
+		r.Matched = true
+		return r, nil
 	}
 
-	ok, err := n.cond.Matches(ctx)
+	cr, err := fn(n.cond)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
+	r.Children = append(r.Children, cr)
+	r.Matched = cr.Matched
 
 	if n.next == nil {
-		return ok, nil
+		return r, nil
 	} else if n.next.cond == nil {
-		return false, errors.New("no condition in last node")
+		return nil, errors.New("no condition in last node")
 	}
 
 	switch n.op {
 	case Or:
-		if ok {
-			return ok, nil
+		if cr.Matched {
+			return r, nil
 		} else {
-			return n.next.Matches(ctx)
+			return n.next.eval(fn, r)
 		}
 	case And:
-		if ok {
-			return n.next.Matches(ctx)
+		if cr.Matched {
+			return n.next.eval(fn, r)
 		} else {
-			return false, nil
+			return r, nil
 		}
 	}
 
-	return false, fmt.Errorf("error condition operator %d", n.op)
+	return nil, fmt.Errorf("error condition operator %d", n.op)
 }
 
 // conditional is a Condition implemented by link of Condition(s).
@@ -279,6 +472,28 @@ func (c *conditional) Matches(ctx Context) (bool, error) {
 	return c.head.Matches(ctx)
 }
 
+// evaluate returns the Result of the linked conditions, the Result of the
+// only condition is returned directly when there is only one condition.
+func (c *conditional) evaluate(ctx Context) (*Result, error) {
+	r, err := c.head.eval(evaluateAll(ctx), nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Children) == 1 && c.head.next == nil {
+		return r.Children[0], nil
+	}
+	var ops []string
+	for n := c.head; n.next != nil; n = n.next {
+		if n.op == Or {
+			ops = append(ops, "Or")
+		} else {
+			ops = append(ops, "And")
+		}
+	}
+	r.Condition = "Conditional(" + strings.Join(ops, ", ") + ")"
+	return r, nil
+}
+
 // Or sets a Or operator.
 func (c *conditional) Or() *conditional {
 	n := &node{}
@@ -387,7 +602,7 @@ func (c *conditional) OnSingleBean(selector util.BeanSelector) *conditional {
 }
 
 // OnExpression returns a conditional that starts with a Condition that returns
-// true when an expression returns true.
+// true when an expression returns true, see onExpression for the syntax.
 func OnExpression(expression string) *conditional {
 	return New().OnExpression(expression)
 }
@@ -409,12 +624,15 @@ func (c *conditional) OnMatches(fn func(ctx Context) (bool, error)) *conditional
 }
 
 // OnProfile returns a conditional that starts with a Condition that returns true
-// when property value equals to profile.
-func OnProfile(profile string) *conditional {
-	return New().OnProfile(profile)
+// when the active profiles match a profile expression, such as `prod & !eu` or
+// `(staging | qa)`, an invalid expression is reported when evaluating.
+func OnProfile(expression string) *conditional {
+	return New().OnProfile(expression)
 }
 
-// OnProfile adds a Condition that returns true when property value equals to profile.
-func (c *conditional) OnProfile(profile string) *conditional {
-	return c.OnProperty("spring.profiles.active", HavingValue(profile))
+// OnProfile adds a Condition that returns true when the active profiles match a
+// profile expression.
+func (c *conditional) OnProfile(expression string) *conditional {
+	e, err := parseProfiles(expression)
+	return c.On(&onProfile{expression: expression, expr: e, err: err})
 }
@@ -18,6 +18,10 @@ package cond_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
 
 	"github.com/go-spring/spring-base/assert"
@@ -348,12 +352,134 @@ func TestOnSingleBean(t *testing.T) {
 }
 
 func TestOnExpression(t *testing.T) {
-	ctrl := gomock.NewController(t)
-	defer ctrl.Finish()
-	ctx := cond.NewMockContext(ctrl)
-	ok, err := cond.OnExpression("").Matches(ctx)
-	assert.Error(t, err, "unimplemented method")
-	assert.False(t, ok)
+	t.Run("property tree", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("server.port[0]").Return(false)
+		ctx.EXPECT().Has("server.port").Return(true)
+		ctx.EXPECT().Prop("server.port").Return("8080")
+		ctx.EXPECT().Has("mq.types[0]").Return(true).Times(3)
+		ctx.EXPECT().Has("mq.types[1]").Return(true)
+		ctx.EXPECT().Has("mq.types[2]").Return(false)
+		ctx.EXPECT().Prop("mq.types[0]").Return("redis")
+		ctx.EXPECT().Prop("mq.types[1]").Return("kafka")
+		c := cond.OnExpression("int(${server.port}) > 1024 && 'kafka' in ${mq.types}")
+		r, err := cond.Evaluate(c, ctx)
+		assert.Nil(t, err)
+		assert.True(t, r.Matched)
+		assert.Equal(t, r.Detail, `server.port="8080", mq.types=[redis kafka]`)
+	})
+	t.Run("comma string", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("mq.types[0]").Return(false).AnyTimes()
+		ctx.EXPECT().Has("mq.types").Return(true).AnyTimes()
+		ctx.EXPECT().Prop("mq.types").Return("redis, kafka").AnyTimes()
+		c := cond.OnExpression("'kafka' in ${mq.types} && ${mq.types} == 'redis, kafka'")
+		r, err := cond.Evaluate(c, ctx)
+		assert.Nil(t, err)
+		assert.True(t, r.Matched)
+		assert.Equal(t, r.Detail, `mq.types=[redis kafka], mq.types="redis, kafka"`)
+	})
+	t.Run("functions", func(t *testing.T) {
+		testcases := []struct {
+			expression string
+			expect     bool
+		}{
+			{"${a:=x} == 'x'", true},
+			{"float(${a:=0.5}) < 1", true},
+			{"bool(${a:=true})", true},
+			{"duration(${a:=1m}) > duration('30s')", true},
+			{"'b' in list(${a:=a,b})", true},
+			{"'b' in ${a:=a,b} && 'c' not in ${a:=a,b}", true},
+			{"compareVersion(${a:=v1.10.0}, '1.9') > 0", true},
+			{"${a:=abc} matches '^a'", true},
+			{"${a:=1} == ${a:=1} && ${a:=1} != '2'", true},
+		}
+		for _, c := range testcases {
+			ctrl := gomock.NewController(t)
+			ctx := cond.NewMockContext(ctrl)
+			ctx.EXPECT().Has(gomock.Any()).Return(false).AnyTimes()
+			ok, err := cond.OnExpression(c.expression).Matches(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, ok, c.expect)
+			ctrl.Finish()
+		}
+	})
+	t.Run("error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has(gomock.Any()).Return(false).AnyTimes()
+		_, err := cond.OnExpression("${a} == 1").Matches(ctx)
+		assert.Error(t, err, `property "a" not exist`)
+		_, err = cond.OnExpression("int(${a:=x}) == 1").Matches(ctx)
+		assert.Error(t, err, `int\("x"\): invalid syntax`)
+		_, err = cond.OnExpression("${a:=1}").Matches(ctx)
+		assert.Error(t, err, `eval "\${a:=1}" doesn't return bool`)
+	})
+}
+
+func TestOnPropertyTyped(t *testing.T) {
+	testcases := []struct {
+		value  string
+		option cond.PropertyOption
+		expect bool
+	}{
+		{"8080", cond.HavingNumber(">= 1024"), true},
+		{"80", cond.HavingNumber(">= 1024"), false},
+		{"0.5", cond.HavingNumber("0.5"), true},
+		{"0.5", cond.HavingNumber("!=0.5"), false},
+		{"10s", cond.HavingDuration("< 30s"), true},
+		{"1m", cond.HavingDuration("< 30s"), false},
+		{"1.10.0", cond.HavingVersion(">= 1.9"), true},
+		{"v1.2.0-rc.1", cond.HavingVersion("< 1.2.0"), true},
+		{"1.2.0-rc.2", cond.HavingVersion("> 1.2.0-rc.10"), false},
+		{"1.2.0+build.5", cond.HavingVersion("== 1.2"), true},
+		{"cn-north-1", cond.MatchingRegex(`^cn-`), true},
+		{"us-east-1", cond.MatchingRegex(`^cn-`), false},
+		{"kafka, redis", cond.ContainingItem("redis"), true},
+		{"kafka, redis", cond.ContainingItem("rabbit"), false},
+	}
+	for _, c := range testcases {
+		ctrl := gomock.NewController(t)
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("a").Return(true)
+		ctx.EXPECT().Has("a[0]").Return(false).AnyTimes()
+		ctx.EXPECT().Prop("a").Return(c.value)
+		ok, err := cond.OnProperty("a", c.option).Matches(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, ok, c.expect)
+		ctrl.Finish()
+	}
+	t.Run("list", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("a").Return(true)
+		ctx.EXPECT().Has("a[0]").Return(true).Times(2)
+		ctx.EXPECT().Has("a[1]").Return(false)
+		ctx.EXPECT().Prop("a[0]").Return("kafka")
+		r, err := cond.Evaluate(cond.OnProperty("a", cond.ContainingItem("kafka")), ctx)
+		assert.Nil(t, err)
+		assert.True(t, r.Matched)
+		assert.Equal(t, r.String(), "OnProperty(name=a, containingItem=kafka) matched, a=[kafka]")
+	})
+	t.Run("error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("a").Return(true).AnyTimes()
+		ctx.EXPECT().Prop("a").Return("abc").AnyTimes()
+		_, err := cond.OnProperty("a", cond.HavingNumber("> 1")).Matches(ctx)
+		assert.Error(t, err, `property a="abc": not a number`)
+		_, err = cond.OnProperty("a", cond.HavingVersion("> x")).Matches(ctx)
+		assert.Error(t, err, `invalid version "x"`)
+		_, err = cond.OnProperty("a", cond.MatchingRegex("(")).Matches(ctx)
+		assert.Error(t, err, "invalid regex")
+	})
 }
 
 func TestOnMatches(t *testing.T) {
@@ -397,6 +523,170 @@ func TestOnProfile(t *testing.T) {
 		assert.Nil(t, err)
 		assert.True(t, ok)
 	})
+	t.Run("expression", func(t *testing.T) {
+		testcases := []struct {
+			expression string
+			active     string
+			expect     bool
+		}{
+			{"prod & !eu", "prod", true},
+			{"prod & !eu", "prod,eu", false},
+			{"prod & !eu", "dev", false},
+			{"(staging | qa)", "qa", true},
+			{"(staging | qa)", "prod", false},
+			{"!(staging | qa) & prod", "prod, us", true},
+			{"(prod & eu) | dev", "dev", true},
+			{"(prod & eu) | dev", "prod", false},
+		}
+		for _, c := range testcases {
+			ctrl := gomock.NewController(t)
+			ctx := cond.NewMockContext(ctrl)
+			ctx.EXPECT().Has("spring.profiles.active").Return(true)
+			ctx.EXPECT().Prop("spring.profiles.active").Return(c.active)
+			ok, err := cond.OnProfile(c.expression).Matches(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, ok, c.expect)
+			ctrl.Finish()
+		}
+	})
+	t.Run("invalid expression", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		for _, s := range []string{"", "a & b | c", "(a | b", "a &", "a b", "!"} {
+			_, err := cond.OnProfile(s).Matches(ctx)
+			assert.Error(t, err, "invalid profile expression")
+		}
+	})
+}
+
+func TestOnEnv(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := cond.NewMockContext(ctrl)
+	os.Setenv("COND_TEST_ENV", "on")
+	defer os.Unsetenv("COND_TEST_ENV")
+	ok, err := cond.OnEnv("COND_TEST_ENV", "").Matches(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = cond.OnEnv("COND_TEST_ENV", "on").Matches(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = cond.OnEnv("COND_TEST_ENV", "off").Matches(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = cond.OnEnv("COND_TEST_MISSING_ENV", "").Matches(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+type resourceContext struct {
+	*cond.MockContext
+	resources map[string]bool
+}
+
+func (ctx *resourceContext) HasResource(path string) (bool, error) {
+	return ctx.resources[path], nil
+}
+
+func TestOnResource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cond")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	secret := filepath.Join(dir, "secret")
+	err = ioutil.WriteFile(secret, []byte("123456"), 0600)
+	assert.Nil(t, err)
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := &resourceContext{
+		MockContext: cond.NewMockContext(ctrl),
+		resources:   map[string]bool{"app.yaml": true},
+	}
+	testcases := []struct {
+		path   string
+		expect bool
+	}{
+		{secret, true},
+		{filepath.Join(dir, "missing"), false},
+		{"app.yaml", true},
+		{"missing.yaml", false},
+	}
+	for _, c := range testcases {
+		ok, err := cond.OnResource(c.path).Matches(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, ok, c.expect)
+	}
+}
+
+func TestOnExecutable(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := cond.NewMockContext(ctrl)
+	ok, err := cond.OnExecutable("go").Matches(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = cond.OnExecutable("go-spring-no-such-executable").Matches(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestOnPlatform(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := cond.NewMockContext(ctrl)
+	ok, err := cond.OnGOOS("plan8", runtime.GOOS).Matches(ctx)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = cond.OnGOARCH("arm0").Matches(ctx)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	r, err := cond.Evaluate(cond.OnGOARCH("arm0").Or().OnGOOS(runtime.GOOS).And().OnEnv("COND_TEST_MISSING_ENV", ""), ctx)
+	assert.Nil(t, err)
+	assert.False(t, r.Matched)
+	assert.Equal(t, r.String(), `Conditional(Or, And) unmatched
+  OnGOARCH(arm0) unmatched, GOARCH=`+runtime.GOARCH+`
+  OnGOOS(`+runtime.GOOS+`) matched, GOOS=`+runtime.GOOS+`
+  OnEnv(name=COND_TEST_MISSING_ENV) unmatched, COND_TEST_MISSING_ENV is missing`)
+}
+
+func TestEnvConditional(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+	ctx := cond.NewMockContext(ctrl)
+	os.Setenv("COND_TEST_ENV", "on")
+	defer os.Unsetenv("COND_TEST_ENV")
+	missingExec := "go-spring-no-such-executable"
+	testcases := []struct {
+		cond   cond.Condition
+		expect bool
+	}{
+		{cond.OnEnv("COND_TEST_ENV", "on").And().OnExecutable("go"), true},
+		{cond.OnEnv("COND_TEST_ENV", "off").And().OnExecutable("go"), false},
+		{cond.OnEnv("COND_TEST_ENV", "").And().OnExecutable(missingExec), false},
+		{cond.OnExecutable(missingExec).Or().OnEnv("COND_TEST_ENV", ""), true},
+		{cond.OnExecutable(missingExec).Or().OnEnv("COND_TEST_MISSING_ENV", ""), false},
+		{cond.OnGOOS(runtime.GOOS).And().OnGOARCH(runtime.GOARCH), true},
+		{cond.OnGOOS(runtime.GOOS).And().OnGOARCH("arm0"), false},
+		{cond.OnGOOS("plan8").Or().OnGOARCH(runtime.GOARCH), true},
+		{cond.OnGOOS("plan8").Or().OnGOARCH("arm0"), false},
+		{cond.OnGOOS(runtime.GOOS).And().OnEnv("COND_TEST_ENV", "on").And().OnExecutable("go"), true},
+		{cond.OnGOOS("plan8").Or().OnEnv("COND_TEST_ENV", "on").And().OnExecutable(missingExec), false},
+		{cond.On(cond.Not(cond.OnEnv("COND_TEST_MISSING_ENV", ""))).And().OnGOARCH(runtime.GOARCH), true},
+	}
+	for i, c := range testcases {
+		ok, err := c.cond.Matches(ctx)
+		assert.Nil(t, err)
+		if ok != c.expect {
+			t.Errorf("testcase %d: expect %v but got %v", i, c.expect, ok)
+		}
+	}
+	r, err := cond.Evaluate(cond.OnEnv("COND_TEST_ENV", "on").And().OnExecutable(missingExec), ctx)
+	assert.Nil(t, err)
+	assert.False(t, r.Matched)
+	assert.Equal(t, r.String(), `Conditional(And) unmatched
+  OnEnv(name=COND_TEST_ENV, value=on) matched, COND_TEST_ENV="on"
+  OnExecutable(`+missingExec+`) unmatched, `+missingExec+` not found`)
 }
 
 func TestConditional(t *testing.T) {
@@ -468,3 +758,44 @@ func TestGroup(t *testing.T) {
 		assert.True(t, ok)
 	})
 }
+
+func TestEvaluate(t *testing.T) {
+	t.Run("conditional", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("a").Return(true)
+		ctx.EXPECT().Prop("a").Return("1")
+		ctx.EXPECT().Find("b").Return(nil, nil)
+		c := cond.OnProperty("a", cond.HavingValue("1")).OnBean("b").Or().OnMissingProperty("c")
+		ctx.EXPECT().Has("c").Return(false)
+		r, err := cond.Evaluate(c, ctx)
+		assert.Nil(t, err)
+		assert.True(t, r.Matched)
+		assert.Equal(t, r.String(), `Conditional(And, Or) matched
+  OnProperty(name=a, havingValue=1) matched, a="1"
+  OnBean(selector=b) unmatched, found 0 beans []
+  OnMissingProperty(name=c) matched, c is missing`)
+	})
+	t.Run("group", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		ctx.EXPECT().Has("a").Return(false)
+		c := cond.Group(cond.And, cond.Not(cond.OnProperty("a", cond.MatchIfMissing())), cond.OK())
+		r, err := cond.Evaluate(c, ctx)
+		assert.Nil(t, err)
+		assert.False(t, r.Matched)
+		assert.Equal(t, r.String(), `Group(And) unmatched
+  Not unmatched
+    OnProperty(name=a, matchIfMissing) matched, a is missing`)
+	})
+	t.Run("error", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		defer ctrl.Finish()
+		ctx := cond.NewMockContext(ctrl)
+		r, err := cond.Evaluate(cond.Group(cond.Or), ctx)
+		assert.Error(t, err, "no condition in group")
+		assert.Nil(t, r)
+	})
+}
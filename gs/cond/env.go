@@ -0,0 +1,199 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cond
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ResourceFinder is implemented by the Context that can find resources, such
+// as the IoC container of App, which finds resources by its ResourceLocator
+// chain.
+type ResourceFinder interface {
+	HasResource(path string) (bool, error)
+}
+
+// onEnv is a Condition that checks an environment variable and its value.
+type onEnv struct {
+	name  string
+	value string
+}
+
+func (c *onEnv) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onEnv) evaluate(ctx Context) (*Result, error) {
+	r := &Result{Condition: fmt.Sprintf("OnEnv(name=%s", c.name)}
+	if c.value != "" {
+		r.Condition += fmt.Sprintf(", value=%s", c.value)
+	}
+	r.Condition += ")"
+	val, ok := os.LookupEnv(c.name)
+	if !ok {
+		r.Detail = fmt.Sprintf("%s is missing", c.name)
+		return r, nil
+	}
+	r.Detail = fmt.Sprintf("%s=%q", c.name, val)
+	r.Matched = c.value == "" || c.value == val
+	return r, nil
+}
+
+// onResource is a Condition that returns true when a resource exists.
+type onResource struct {
+	path string
+}
+
+func (c *onResource) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onResource) evaluate(ctx Context) (*Result, error) {
+	r := &Result{Condition: fmt.Sprintf("OnResource(%s)", c.path)}
+	if f, ok := ctx.(ResourceFinder); ok && !filepath.IsAbs(c.path) {
+		found, err := f.HasResource(c.path)
+		if err != nil {
+			return nil, err
+		}
+		r.Matched = found
+	} else {
+		_, err := os.Stat(c.path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		r.Matched = err == nil
+	}
+	if r.Matched {
+		r.Detail = fmt.Sprintf("%s exists", c.path)
+	} else {
+		r.Detail = fmt.Sprintf("%s not found", c.path)
+	}
+	return r, nil
+}
+
+// onExecutable is a Condition that returns true when an executable is found in
+// the directories named by the PATH environment variable.
+type onExecutable struct {
+	name string
+}
+
+func (c *onExecutable) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onExecutable) evaluate(ctx Context) (*Result, error) {
+	r := &Result{Condition: fmt.Sprintf("OnExecutable(%s)", c.name)}
+	if path, err := exec.LookPath(c.name); err == nil {
+		r.Matched = true
+		r.Detail = fmt.Sprintf("found %s", path)
+	} else {
+		r.Detail = fmt.Sprintf("%s not found", c.name)
+	}
+	return r, nil
+}
+
+// onPlatform is a Condition that returns true when the GOOS or GOARCH is one
+// of the expected values.
+type onPlatform struct {
+	name   string // GOOS or GOARCH
+	actual string
+	expect []string
+}
+
+func (c *onPlatform) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onPlatform) evaluate(ctx Context) (*Result, error) {
+	r := &Result{
+		Condition: fmt.Sprintf("On%s(%s)", c.name, strings.Join(c.expect, ",")),
+		Detail:    fmt.Sprintf("%s=%s", c.name, c.actual),
+	}
+	for _, s := range c.expect {
+		if s == c.actual {
+			r.Matched = true
+			break
+		}
+	}
+	return r, nil
+}
+
+// OnEnv returns a conditional that starts with a Condition that returns true
+// when the environment variable exists and equals to value, an empty value
+// only requires the environment variable exists.
+func OnEnv(name string, value string) *conditional {
+	return New().OnEnv(name, value)
+}
+
+// OnEnv adds a Condition that returns true when the environment variable
+// exists and equals to value.
+func (c *conditional) OnEnv(name string, value string) *conditional {
+	return c.On(&onEnv{name: name, value: value})
+}
+
+// OnResource returns a conditional that starts with a Condition that returns
+// true when the resource exists, such as a mounted secret file or a unix socket.
+// A relative path is found by the Context when it implements ResourceFinder,
+// otherwise and for an absolute path the file system is checked directly.
+func OnResource(path string) *conditional {
+	return New().OnResource(path)
+}
+
+// OnResource adds a Condition that returns true when the resource exists.
+func (c *conditional) OnResource(path string) *conditional {
+	return c.On(&onResource{path: path})
+}
+
+// OnExecutable returns a conditional that starts with a Condition that returns
+// true when the executable is found in the PATH.
+func OnExecutable(name string) *conditional {
+	return New().OnExecutable(name)
+}
+
+// OnExecutable adds a Condition that returns true when the executable is found
+// in the PATH.
+func (c *conditional) OnExecutable(name string) *conditional {
+	return c.On(&onExecutable{name: name})
+}
+
+// OnGOOS returns a conditional that starts with a Condition that returns true
+// when runtime.GOOS is one of goos.
+func OnGOOS(goos ...string) *conditional {
+	return New().OnGOOS(goos...)
+}
+
+// OnGOOS adds a Condition that returns true when runtime.GOOS is one of goos.
+func (c *conditional) OnGOOS(goos ...string) *conditional {
+	return c.On(&onPlatform{name: "GOOS", actual: runtime.GOOS, expect: goos})
+}
+
+// OnGOARCH returns a conditional that starts with a Condition that returns true
+// when runtime.GOARCH is one of goarch.
+func OnGOARCH(goarch ...string) *conditional {
+	return New().OnGOARCH(goarch...)
+}
+
+// OnGOARCH adds a Condition that returns true when runtime.GOARCH is one of
+// goarch.
+func (c *conditional) OnGOARCH(goarch ...string) *conditional {
+	return c.On(&onPlatform{name: "GOARCH", actual: runtime.GOARCH, expect: goarch})
+}
@@ -0,0 +1,196 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cond
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-spring/spring-core/expr"
+)
+
+// exprFuncs are the functions that can be used in OnExpression.
+var exprFuncs = map[string]interface{}{
+	"int": func(v interface{}) (int, error) {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		i, err := strconv.ParseInt(s, 0, 64)
+		if err != nil {
+			return 0, fmt.Errorf("int(%q): invalid syntax", s)
+		}
+		return int(i), nil
+	},
+	"float": func(v interface{}) (float64, error) {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, fmt.Errorf("float(%q): invalid syntax", s)
+		}
+		return f, nil
+	},
+	"bool": func(v interface{}) (bool, error) {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return false, fmt.Errorf("bool(%q): invalid syntax", s)
+		}
+		return b, nil
+	},
+	"duration": func(v interface{}) (int, error) {
+		s := strings.TrimSpace(fmt.Sprint(v))
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return 0, fmt.Errorf("duration(%q): invalid syntax", s)
+		}
+		return int(d), nil
+	},
+	"list": func(v interface{}) []interface{} {
+		if a, ok := v.([]interface{}); ok {
+			return a
+		}
+		var a []interface{}
+		for _, s := range strings.Split(fmt.Sprint(v), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				a = append(a, s)
+			}
+		}
+		return a
+	},
+	"compareVersion": func(a, b interface{}) (int, error) {
+		return CompareVersion(fmt.Sprint(a), fmt.Sprint(b))
+	},
+}
+
+// onExpression is a Condition that returns true when an expression returns
+// true. The expression is evaluated by the expr package, the ${key} and
+// ${key:=default} references in it are replaced by the property values, which
+// are strings, or lists of strings when the properties are defined by indexes.
+// A reference used as the right operand of `in` is always a list, so a comma
+// separated string works there too. The functions int, float, bool, duration,
+// list and compareVersion are used to convert the strings, for example:
+//
+//	int(${server.port}) > 1024 && 'kafka' in ${mq.types}
+//
+// list splits a comma separated string and returns a list unchanged.
+type onExpression struct {
+	expression string
+}
+
+func (c *onExpression) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onExpression) evaluate(ctx Context) (*Result, error) {
+
+	env := make(map[string]interface{}, len(exprFuncs))
+	for k, v := range exprFuncs {
+		env[k] = v
+	}
+
+	var (
+		buf     strings.Builder
+		details []string
+	)
+
+	vars := make(map[string]string)
+	var refs []string // refs[i] is the original reference of the variable $pi
+	s := c.expression
+	for {
+		start := strings.Index(s, "${")
+		if start < 0 {
+			buf.WriteString(s)
+			break
+		}
+		end := strings.IndexByte(s[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("expression %q: missing '}'", c.expression)
+		}
+		end += start
+
+		ref := s[start+2 : end]
+		asList := isInOperand(buf.String() + s[:start])
+		cacheKey := ref
+		if asList {
+			cacheKey += "|in"
+		}
+		name, ok := vars[cacheKey]
+		if !ok {
+			key, def, hasDef := ref, "", false
+			if i := strings.Index(ref, ":="); i >= 0 {
+				key, def, hasDef = ref[:i], ref[i+2:], true
+			}
+			key = strings.TrimSpace(key)
+			var val interface{}
+			switch {
+			case ctx.Has(key + "[0]"), asList && ctx.Has(key):
+				var a []interface{}
+				for _, item := range propList(ctx, key) {
+					a = append(a, item)
+				}
+				val = a
+				details = append(details, fmt.Sprintf("%s=%v", key, a))
+			case ctx.Has(key):
+				val = ctx.Prop(key)
+				details = append(details, fmt.Sprintf("%s=%q", key, val))
+			case hasDef:
+				val = def
+				if asList {
+					val = exprFuncs["list"].(func(interface{}) []interface{})(def)
+				}
+				details = append(details, fmt.Sprintf("%s is missing", key))
+			default:
+				return nil, fmt.Errorf("expression %q: property %q not exist", c.expression, key)
+			}
+			name = fmt.Sprintf("$p%d", len(refs))
+			vars[cacheKey] = name
+			refs = append(refs, "${"+ref+"}")
+			env[name] = val
+		}
+
+		buf.WriteString(s[:start])
+		buf.WriteString(name)
+		s = s[end+1:]
+	}
+
+	ok, err := expr.EvalEnv(buf.String(), env)
+	if err != nil {
+		// Maps the variables back to the original references, the longer names
+		// go first so that $p1 doesn't match the prefix of $p10.
+		var oldnew []string
+		for i := len(refs) - 1; i >= 0; i-- {
+			oldnew = append(oldnew, fmt.Sprintf("$p%d", i), refs[i])
+		}
+		return nil, errors.New(strings.NewReplacer(oldnew...).Replace(err.Error()))
+	}
+	return &Result{
+		Condition: fmt.Sprintf("OnExpression(%s)", c.expression),
+		Matched:   ok,
+		Detail:    strings.Join(details, ", "),
+	}, nil
+}
+
+// isInOperand returns whether the next reference is the right operand of
+// the `in` operator, s is the expression before the reference.
+func isInOperand(s string) bool {
+	s = strings.TrimRight(s, " \t\n")
+	if !strings.HasSuffix(s, "in") || len(s) == 2 {
+		return false
+	}
+	return strings.IndexByte(" \t\n)'\"", s[len(s)-3]) >= 0
+}
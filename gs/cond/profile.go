@@ -0,0 +1,189 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cond
+
+import (
+	"fmt"
+	"strings"
+)
+
+// activeProfiles is the property that lists the active profiles.
+const activeProfiles = "spring.profiles.active"
+
+// profileExpr is a node of a parsed profile expression.
+type profileExpr interface {
+	matches(active map[string]bool) bool
+}
+
+type profileName string
+
+func (e profileName) matches(active map[string]bool) bool {
+	return active[string(e)]
+}
+
+type profileNot struct {
+	e profileExpr
+}
+
+func (e *profileNot) matches(active map[string]bool) bool {
+	return !e.e.matches(active)
+}
+
+type profileGroup struct {
+	and   bool
+	exprs []profileExpr
+}
+
+func (e *profileGroup) matches(active map[string]bool) bool {
+	for _, x := range e.exprs {
+		if x.matches(active) != e.and {
+			return !e.and
+		}
+	}
+	return e.and
+}
+
+// parseProfiles parses a profile expression, which consists of profile names,
+// `!` (not), `&` (and), `|` (or) and parentheses. Like Spring, `&` and `|`
+// can't be mixed without parentheses, e.g. `a & b | c` is invalid while
+// `(a & b) | c` is valid.
+func parseProfiles(s string) (profileExpr, error) {
+	p := &profileParser{s: s}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile expression %q: %w", s, err)
+	}
+	if p.peek() != 0 {
+		return nil, fmt.Errorf("invalid profile expression %q: unexpected %q", s, p.peek())
+	}
+	return e, nil
+}
+
+type profileParser struct {
+	s   string
+	pos int
+}
+
+// peek returns the next non-space character, or returns 0 at the end.
+func (p *profileParser) peek() byte {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+	if p.pos == len(p.s) {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *profileParser) parseExpr() (profileExpr, error) {
+
+	e, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var g *profileGroup
+	for {
+		op := p.peek()
+		if op != '&' && op != '|' {
+			break
+		}
+		p.pos++
+		if g == nil {
+			g = &profileGroup{and: op == '&', exprs: []profileExpr{e}}
+		} else if g.and != (op == '&') {
+			return nil, fmt.Errorf("mixed '&' and '|' without parentheses")
+		}
+		x, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		g.exprs = append(g.exprs, x)
+	}
+
+	if g == nil {
+		return e, nil
+	}
+	return g, nil
+}
+
+func (p *profileParser) parseOperand() (profileExpr, error) {
+	switch c := p.peek(); c {
+	case 0:
+		return nil, fmt.Errorf("unexpected end")
+	case '!':
+		p.pos++
+		e, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return &profileNot{e: e}, nil
+	case '(':
+		p.pos++
+		e, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing ')'")
+		}
+		p.pos++
+		return e, nil
+	default:
+		start := p.pos
+		for p.pos < len(p.s) && !strings.ContainsRune("&|!() \t", rune(p.s[p.pos])) {
+			p.pos++
+		}
+		if p.pos == start {
+			return nil, fmt.Errorf("unexpected %q", c)
+		}
+		return profileName(p.s[start:p.pos]), nil
+	}
+}
+
+// onProfile is a Condition that returns true when the active profiles match
+// a profile expression.
+type onProfile struct {
+	expression string
+	expr       profileExpr
+	err        error
+}
+
+func (c *onProfile) Matches(ctx Context) (bool, error) {
+	return matches(c, ctx)
+}
+
+func (c *onProfile) evaluate(ctx Context) (*Result, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	r := &Result{Condition: fmt.Sprintf("OnProfile(%s)", c.expression)}
+	active := make(map[string]bool)
+	if ctx.Has(activeProfiles) {
+		val := ctx.Prop(activeProfiles)
+		for _, s := range strings.Split(val, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				active[s] = true
+			}
+		}
+		r.Detail = fmt.Sprintf("%s=%q", activeProfiles, val)
+	} else {
+		r.Detail = fmt.Sprintf("%s is missing", activeProfiles)
+	}
+	r.Matched = c.expr.matches(active)
+	return r, nil
+}
@@ -0,0 +1,288 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cond
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// valueMatcher matches the value of a property in a typed way.
+type valueMatcher struct {
+	desc  string
+	match func(ctx Context, name string) (detail string, ok bool, err error)
+}
+
+// matchScalar returns a valueMatcher that matches the string value of a property.
+func matchScalar(desc string, fn func(val string) (bool, error)) *valueMatcher {
+	return &valueMatcher{
+		desc: desc,
+		match: func(ctx Context, name string) (string, bool, error) {
+			val := ctx.Prop(name)
+			ok, err := fn(val)
+			if err != nil {
+				return "", false, fmt.Errorf("property %s=%q: %w", name, val, err)
+			}
+			return fmt.Sprintf("%s=%q", name, val), ok, nil
+		},
+	}
+}
+
+// comparison is a parsed comparison such as `>= 1024`, the operator defaults
+// to `==` when omitted.
+type comparison struct {
+	op      string
+	operand string
+}
+
+func parseComparison(s string) comparison {
+	s = strings.TrimSpace(s)
+	for _, op := range []string{"==", "!=", ">=", "<=", ">", "<"} {
+		if strings.HasPrefix(s, op) {
+			return comparison{op: op, operand: strings.TrimSpace(s[len(op):])}
+		}
+	}
+	return comparison{op: "==", operand: s}
+}
+
+// matches returns whether the result of comparing a value with the operand,
+// which is -1, 0 or 1, satisfies the operator.
+func (c comparison) matches(r int) bool {
+	switch c.op {
+	case "!=":
+		return r != 0
+	case ">=":
+		return r >= 0
+	case "<=":
+		return r <= 0
+	case ">":
+		return r > 0
+	case "<":
+		return r < 0
+	default:
+		return r == 0
+	}
+}
+
+// compareWith returns a valueMatcher that parses the property value and the
+// operand with parse and then compares them with compare.
+func compareWith(kind string, s string, parse func(string) (interface{}, error), compare func(a, b interface{}) int) *valueMatcher {
+	c := parseComparison(s)
+	operand, err := parse(c.operand)
+	return matchScalar(fmt.Sprintf("having%s%s%s", kind, c.op, c.operand), func(val string) (bool, error) {
+		if err != nil {
+			return false, fmt.Errorf("invalid %s %q", strings.ToLower(kind), c.operand)
+		}
+		v, err := parse(strings.TrimSpace(val))
+		if err != nil {
+			return false, fmt.Errorf("not a %s", strings.ToLower(kind))
+		}
+		return c.matches(compare(v, operand)), nil
+	})
+}
+
+func parseNumber(s string) (interface{}, error) {
+	return strconv.ParseFloat(s, 64)
+}
+
+func parseDuration(s string) (interface{}, error) {
+	return time.ParseDuration(s)
+}
+
+func parseVersion(s string) (interface{}, error) {
+	if _, err := CompareVersion(s, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// HavingNumber sets a Condition to return true when property value is a number
+// and satisfies the comparison, such as `>= 1024`, `< 0.5` or `8080`.
+func HavingNumber(comparison string) PropertyOption {
+	return func(c *onProperty) {
+		c.matcher = compareWith("Number", comparison, parseNumber, func(a, b interface{}) int {
+			return compareFloat(a.(float64), b.(float64))
+		})
+	}
+}
+
+// HavingDuration sets a Condition to return true when property value is a
+// duration and satisfies the comparison, such as `< 30s` or `>= 1h30m`.
+func HavingDuration(comparison string) PropertyOption {
+	return func(c *onProperty) {
+		c.matcher = compareWith("Duration", comparison, parseDuration, func(a, b interface{}) int {
+			return compareFloat(float64(a.(time.Duration)), float64(b.(time.Duration)))
+		})
+	}
+}
+
+// HavingVersion sets a Condition to return true when property value is a
+// semantic version and satisfies the comparison, such as `>= 1.2.0`.
+func HavingVersion(comparison string) PropertyOption {
+	return func(c *onProperty) {
+		c.matcher = compareWith("Version", comparison, parseVersion, func(a, b interface{}) int {
+			r, _ := CompareVersion(a.(string), b.(string))
+			return r
+		})
+	}
+}
+
+// MatchingRegex sets a Condition to return true when property value matches
+// the regular expression, the pattern is not anchored unless using ^ and $.
+func MatchingRegex(pattern string) PropertyOption {
+	return func(c *onProperty) {
+		r, err := regexp.Compile(pattern)
+		c.matcher = matchScalar(fmt.Sprintf("matchingRegex=%s", pattern), func(val string) (bool, error) {
+			if err != nil {
+				return false, fmt.Errorf("invalid regex %q: %w", pattern, err)
+			}
+			return r.MatchString(val), nil
+		})
+	}
+}
+
+// ContainingItem sets a Condition to return true when property is a list that
+// contains the item, the list can be defined either by indexes, such as a[0],
+// or by a comma separated string.
+func ContainingItem(item string) PropertyOption {
+	return func(c *onProperty) {
+		c.matcher = &valueMatcher{
+			desc: fmt.Sprintf("containingItem=%s", item),
+			match: func(ctx Context, name string) (string, bool, error) {
+				items := propList(ctx, name)
+				for _, s := range items {
+					if s == item {
+						return fmt.Sprintf("%s=%v", name, items), true, nil
+					}
+				}
+				return fmt.Sprintf("%s=%v", name, items), false, nil
+			},
+		}
+	}
+}
+
+// propList returns the items of a list property, which is defined either by
+// indexes or by a comma separated string.
+func propList(ctx Context, name string) []string {
+	var items []string
+	if ctx.Has(name + "[0]") {
+		for i := 0; ; i++ {
+			key := fmt.Sprintf("%s[%d]", name, i)
+			if !ctx.Has(key) {
+				break
+			}
+			items = append(items, ctx.Prop(key))
+		}
+		return items
+	}
+	for _, s := range strings.Split(ctx.Prop(name), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			items = append(items, s)
+		}
+	}
+	return items
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// CompareVersion compares two semantic versions and returns -1, 0 or 1. The
+// versions may have a leading v and omit the minor or patch number, such as
+// v1.2, the build metadata is ignored and a pre-release version has lower
+// precedence than the normal version, such as 1.0.0-rc.1 < 1.0.0 .
+func CompareVersion(a, b string) (int, error) {
+
+	parse := func(s string) (nums [3]int, pre []string, err error) {
+		v := strings.TrimPrefix(strings.TrimSpace(s), "v")
+		if i := strings.IndexByte(v, '+'); i >= 0 {
+			v = v[:i]
+		}
+		if i := strings.IndexByte(v, '-'); i >= 0 {
+			pre = strings.Split(v[i+1:], ".")
+			v = v[:i]
+		}
+		ss := strings.Split(v, ".")
+		if len(ss) > 3 {
+			return nums, nil, fmt.Errorf("invalid version %q", s)
+		}
+		for i, x := range ss {
+			n, e := strconv.Atoi(x)
+			if e != nil || n < 0 {
+				return nums, nil, fmt.Errorf("invalid version %q", s)
+			}
+			nums[i] = n
+		}
+		return nums, pre, nil
+	}
+
+	na, pa, err := parse(a)
+	if err != nil {
+		return 0, err
+	}
+	nb, pb, err := parse(b)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < 3; i++ {
+		if r := compareFloat(float64(na[i]), float64(nb[i])); r != 0 {
+			return r, nil
+		}
+	}
+
+	switch {
+	case len(pa) == 0 && len(pb) == 0:
+		return 0, nil
+	case len(pa) == 0:
+		return 1, nil
+	case len(pb) == 0:
+		return -1, nil
+	}
+
+	// numeric identifiers are compared numerically and have lower precedence
+	// than alphanumeric identifiers, which are compared lexically.
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		x, ex := strconv.Atoi(pa[i])
+		y, ey := strconv.Atoi(pb[i])
+		var r int
+		switch {
+		case ex == nil && ey == nil:
+			r = compareFloat(float64(x), float64(y))
+		case ex == nil:
+			r = -1
+		case ey == nil:
+			r = 1
+		default:
+			r = strings.Compare(pa[i], pb[i])
+		}
+		if r != 0 {
+			return r, nil
+		}
+	}
+	return compareFloat(float64(len(pa)), float64(len(pb))), nil
+}
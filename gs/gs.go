@@ -26,6 +26,7 @@ import (
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -50,8 +51,10 @@ const (
 )
 
 var (
-	loggerType  = reflect.TypeOf((*log.Logger)(nil))
-	contextType = reflect.TypeOf((*Context)(nil)).Elem()
+	loggerType      = reflect.TypeOf((*log.Logger)(nil))
+	contextType     = reflect.TypeOf((*Context)(nil)).Elem()
+	scopedProxyType = reflect.TypeOf((*ScopedProxy)(nil))
+	processorType   = reflect.TypeOf((*BeanPostProcessor)(nil)).Elem()
 )
 
 type Container interface {
@@ -60,6 +63,12 @@ type Container interface {
 	Property(key string, value interface{})
 	Object(i interface{}) *BeanDefinition
 	Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition
+	RegisterScope(name string, scope Scope)
+	Intercept(selector util.BeanSelector, fn Interceptor)
+	Listen(l *EventListener) *BeanDefinition
+	Schedule(spec string, fn interface{}, args ...arg.Arg) *ScheduledTask
+	Module(m *Module)
+	NewChild() Container
 	Refresh() error
 	Close()
 }
@@ -83,6 +92,11 @@ type Context interface {
 	Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{}, error)
 	Invoke(fn interface{}, args ...arg.Arg) ([]interface{}, error)
 	Go(fn func(ctx context.Context))
+	Publish(event interface{}) error
+	Graph() *BeanGraph
+	ConditionReport() []*ConditionOutcome
+	Timeline() *StartupTimeline
+	NewChild() Container
 }
 
 // ContextAware injects the Context into a struct as the field GSContext.
@@ -96,6 +110,13 @@ type tempContainer struct {
 	beansByName     map[string][]*BeanDefinition
 	beansByType     map[reflect.Type][]*BeanDefinition
 	mapOfOnProperty map[string]interface{}
+	interceptors    []interceptor
+	intercepted     map[*BeanDefinition][]Interceptor
+	proxies         map[proxyKey]reflect.Value
+	modules         []*Module
+	moduleOutcomes  []*ConditionOutcome
+	locators        []ResourceLocator
+	listenerNames   map[string]int // 监听函数名称的使用次数
 }
 
 // container 是 go-spring 框架的基石，实现了 Martin Fowler 在 << Inversion
@@ -110,10 +131,26 @@ type container struct {
 	logger                  *log.Logger
 	ctx                     context.Context
 	cancel                  context.CancelFunc
-	destroyers              []func()
+	destroyers              []beanDestroyer
+	goroutines              goroutines
+	processors              []BeanPostProcessor
+	scopes                  map[string]Scope
 	state                   refreshState
 	wg                      sync.WaitGroup
 	p                       *dync.Properties
+	scopedProxy             bool
+	parent                  *container // 找不到的 bean 和属性从父容器中查找
+	hasChild                bool
+	parallel                *parallelWiring
+	runtime                 *parallelWiring // 容器刷新之后运行时注入 bean 使用的锁
+	timeline                *StartupTimeline
+	step                    *StartupStep // 刷新步骤的父步骤
+	edges                   []BeanEdge
+	conditions              []*ConditionOutcome
+	graph                   *BeanGraph
+	listeners               []*EventListener
+	tasks                   []*ScheduledTask
+	clock                   Clock `autowire:"?"`
 	ContextAware            bool
 	AllowCircularReferences bool `value:"${spring.main.allow-circular-references:=false}"`
 }
@@ -121,15 +158,24 @@ type container struct {
 // New 创建 IoC 容器。
 func New() Container {
 	ctx, cancel := context.WithCancel(context.Background())
+	p := dync.New()
 	return &container{
-		ctx:    ctx,
-		cancel: cancel,
-		p:      dync.New(),
+		ctx:      ctx,
+		cancel:   cancel,
+		p:        p,
+		timeline: new(StartupTimeline),
+		scopes: map[string]Scope{
+			PrototypeScope: new(prototypeScope),
+			RequestScope:   new(requestScope),
+			RefreshScope:   newRefreshScope(p),
+		},
 		tempContainer: &tempContainer{
 			initProperties:  conf.New(),
 			beansByName:     make(map[string][]*BeanDefinition),
 			beansByType:     make(map[reflect.Type][]*BeanDefinition),
 			mapOfOnProperty: make(map[string]interface{}),
+			intercepted:     make(map[*BeanDefinition][]Interceptor),
+			proxies:         make(map[proxyKey]reflect.Value),
 		},
 	}
 }
@@ -188,6 +234,28 @@ func (c *container) Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition {
 	return c.Accept(NewBean(ctor, args...))
 }
 
+// RegisterScope 注册自定义的作用域，需要注意的是该方法在注入开始后就不能再调用了。
+func (c *container) RegisterScope(name string, scope Scope) {
+	if c.state >= Refreshing {
+		panic(errors.New("should call before Refresh"))
+	}
+	if name == "" || name == SingletonScope {
+		panic(fmt.Errorf("can't register scope %q", name))
+	}
+	c.scopes[name] = scope
+}
+
+// Intercept 为 selector 选中的 bean 添加方法拦截器，拦截器只作用于 bean 通过
+// Export 导出的接口，注入这些接口时注入的是经过拦截器包装的代理对象。被拦截的
+// bean 没有导出接口或者导出的接口没有通过 RegisterProxy 注册代理工厂时，容器刷新
+// 返回错误。需要注意的是该方法在注入开始后就不能再调用了。
+func (c *container) Intercept(selector util.BeanSelector, fn Interceptor) {
+	if c.state >= Refreshing {
+		panic(errors.New("should call before Refresh"))
+	}
+	c.interceptors = append(c.interceptors, interceptor{selector: selector, fn: fn})
+}
+
 // destroyer 保存具有销毁函数的 bean 以及销毁函数的调用顺序。
 type destroyer struct {
 	current *BeanDefinition
@@ -232,11 +300,15 @@ type lazyField struct {
 
 // wiringStack 记录 bean 的注入路径。
 type wiringStack struct {
+	ctx          context.Context // 从作用域获取 bean 实例时使用的 ctx
 	logger       *log.Logger
 	destroyers   *list.List
 	destroyerMap map[string]*destroyer
 	beans        []*BeanDefinition
 	lazyFields   []lazyField
+	field        string       // 正在注入的字段的路径
+	step         *StartupStep // 启动时间线上的当前步骤
+	keys         map[*BeanDefinition][]string
 }
 
 func newWiringStack(logger *log.Logger) *wiringStack {
@@ -280,19 +352,11 @@ func (s *wiringStack) saveDestroyer(b *BeanDefinition) *destroyer {
 }
 
 // sortDestroyers 对具有销毁函数的 bean 按照销毁函数的依赖顺序进行排序。
-func (s *wiringStack) sortDestroyers() []func() {
+func (s *wiringStack) sortDestroyers() []beanDestroyer {
 
-	destroy := func(v reflect.Value, fn interface{}) func() {
-		return func() {
-			if fn == nil {
-				v.Interface().(BeanDestroy).OnDestroy()
-			} else {
-				fnValue := reflect.ValueOf(fn)
-				out := fnValue.Call([]reflect.Value{v})
-				if len(out) > 0 && !out[0].IsNil() {
-					s.logger.Error(out[0].Interface().(error))
-				}
-			}
+	destroy := func(v reflect.Value, fn interface{}) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			return destroyBeanValue(ctx, v, fn)
 		}
 	}
 
@@ -302,15 +366,19 @@ func (s *wiringStack) sortDestroyers() []func() {
 	}
 	destroyers = internal.TripleSort(destroyers, getBeforeDestroyers)
 
-	var ret []func()
+	var ret []beanDestroyer
 	for e := destroyers.Front(); e != nil; e = e.Next() {
 		d := e.Value.(*destroyer).current
-		ret = append(ret, destroy(d.Value(), d.destroy))
+		ret = append(ret, beanDestroyer{bean: d.ID(), fn: destroy(d.Value(), d.destroy)})
 	}
 	return ret
 }
 
+// clear 释放注入过程中使用的临时数据，运行时仍然需要获取 bean 时保留这些数据。
 func (c *container) clear() {
+	if c.ContextAware || c.scopedProxy || c.hasChild || len(c.tasks) > 0 {
+		return
+	}
 	c.tempContainer = nil
 }
 
@@ -324,8 +392,14 @@ func (c *container) refresh(autoClear bool) (err error) {
 	if c.state != Unrefreshed {
 		return errors.New("container already refreshed")
 	}
+	if err = c.inheritProperties(); err != nil {
+		return err
+	}
 	c.state = RefreshInit
 
+	refreshStep := c.timeline.begin(c.step, "refresh", "")
+	defer c.timeline.end(refreshStep)
+
 	c.p.Refresh(c.initProperties)
 
 	start := time.Now()
@@ -343,16 +417,35 @@ func (c *container) refresh(autoClear bool) (err error) {
 
 	c.state = Refreshing
 
+	resolveStep := c.timeline.begin(refreshStep, "resolve", "")
 	for _, b := range c.beans {
 		c.registerBean(b)
 	}
 
+	if err = c.registerProducts(c.beans); err != nil {
+		return err
+	}
+
+	if err = c.replaceBeans(); err != nil {
+		return err
+	}
+
 	for _, b := range c.beans {
 		if err = c.resolveBean(b); err != nil {
 			return err
 		}
 	}
 
+	// 自动配置模块在用户注册的 bean 之后处理。
+	if err = c.resolveModules(); err != nil {
+		return err
+	}
+
+	c.overrideBeans()
+
+	c.conditions = c.conditionReport()
+	c.timeline.end(resolveStep)
+
 	beansById := make(map[string]*BeanDefinition)
 	{
 		for _, b := range c.beans {
@@ -362,6 +455,9 @@ func (c *container) refresh(autoClear bool) (err error) {
 			if b.status != Resolved {
 				return fmt.Errorf("unexpected status %d", b.status)
 			}
+			if _, ok := c.scopes[b.scope]; !ok && !b.isSingleton() {
+				return fmt.Errorf("scope %q not found, %s", b.scope, b)
+			}
 			beanID := b.ID()
 			if d, ok := beansById[beanID]; ok {
 				return fmt.Errorf("found duplicate beans [%s] [%s]", b, d)
@@ -370,7 +466,32 @@ func (c *container) refresh(autoClear bool) (err error) {
 		}
 	}
 
+	for _, i := range c.interceptors {
+		beans, err := c.findBean(i.selector)
+		if err != nil {
+			return err
+		}
+		for _, b := range beans {
+			c.intercepted[b] = append(c.intercepted[b], i.fn)
+		}
+	}
+
+	for _, b := range c.beans {
+		if _, ok := c.intercepted[b]; !ok {
+			continue
+		}
+		if err = checkIntercepted(b); err != nil {
+			return err
+		}
+	}
+
+	if err = c.prepareTasks(); err != nil {
+		return err
+	}
+
 	stack := newWiringStack(c.logger)
+	stack.step = c.timeline.begin(refreshStep, "wire", "")
+	defer c.timeline.end(stack.step)
 
 	defer func() {
 		if err != nil || len(stack.beans) > 0 {
@@ -380,14 +501,54 @@ func (c *container) refresh(autoClear bool) (err error) {
 	}()
 
 	// 按照 bean id 升序注入，保证注入过程始终一致。
+	var keys []string
+	for s := range beansById {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+
+	// 在注入其他 bean 之前收集事件监听器，bean 在初始化时发布的事件也能被接收。
+	listeners := reflect.ValueOf(&c.listeners).Elem()
+	if err = c.wireByTag(listeners, "${event-listener.collection:=*?}", stack); err != nil {
+		return err
+	}
+
+	// 优先创建 BeanPostProcessor 类型的 bean，然后由它们处理其他的 bean 。
 	{
-		var keys []string
-		for s := range beansById {
-			keys = append(keys, s)
+		var processors []*BeanDefinition
+		for _, s := range keys {
+			b := beansById[s]
+			if b.isSingleton() && b.Type().Implements(processorType) {
+				processors = append(processors, b)
+			}
+		}
+		sort.Stable(byOrder(processors))
+		for _, b := range processors {
+			if err = c.wireBean(b, stack); err != nil {
+				return err
+			}
 		}
-		sort.Strings(keys)
+		for _, b := range processors {
+			c.processors = append(c.processors, b.Interface().(BeanPostProcessor))
+		}
+	}
+
+	if parallel, _ := strconv.ParseBool(c.p.Get(SpringRefreshParallel)); parallel {
+		beans := make([]*BeanDefinition, 0, len(keys))
+		for _, s := range keys {
+			if b := beansById[s]; !b.lazy || len(b.tasks) > 0 {
+				beans = append(beans, b)
+			}
+		}
+		if err = c.wireParallel(beans, stack); err != nil {
+			return err
+		}
+	} else {
 		for _, s := range keys {
 			b := beansById[s]
+			if b.lazy && len(b.tasks) == 0 {
+				continue // lazy bean 在第一次注入或者获取时创建
+			}
 			if err = c.wireBean(b, stack); err != nil {
 				return err
 			}
@@ -407,12 +568,17 @@ func (c *container) refresh(autoClear bool) (err error) {
 	}
 
 	c.destroyers = stack.sortDestroyers()
+	c.graph = c.buildGraph()
+	c.edges = nil
+	c.runtime = newParallelWiring()
 	c.state = Refreshed
 
+	c.startTasks()
+
 	cost := time.Now().Sub(start)
 	c.logger.Infof("refresh %d beans cost %v", len(beansById), cost)
 
-	if autoClear && !c.ContextAware {
+	if autoClear {
 		c.clear()
 	}
 
@@ -433,18 +599,28 @@ func (c *container) registerBean(b *BeanDefinition) {
 // resolveBean 判断 bean 的有效性，如果 bean 是无效的则被标记为已删除。
 func (c *container) resolveBean(b *BeanDefinition) error {
 
-	if b.status >= Resolving {
+	if b.status == Deleted || b.status >= Resolving {
 		return nil
 	}
 
 	b.status = Resolving
 
+	// 产品 bean 随着工厂一起被删除
+	if b.factory != nil {
+		if err := c.resolveBean(b.factory); err != nil {
+			return err
+		}
+		if b.factory.status == Deleted {
+			b.outcome = newConditionOutcome(b)
+			b.outcome.Message = fmt.Sprintf("factory %s not found", b.factory)
+			b.status = Deleted
+			return nil
+		}
+	}
+
 	// method bean 先确定 parent bean 是否存在
 	if b.method {
-		selector, ok := b.f.Arg(0)
-		if !ok || selector == "" {
-			selector, _ = b.f.In(0)
-		}
+		selector := parentSelector(b)
 		parents, err := c.findBean(selector)
 		if err != nil {
 			return err
@@ -458,15 +634,22 @@ func (c *container) resolveBean(b *BeanDefinition) error {
 			msg = msg[:len(msg)-2] + "]"
 			return errors.New(msg)
 		} else if n == 0 {
+			b.outcome = newConditionOutcome(b)
+			b.outcome.Message = fmt.Sprintf("parent bean %v not found", selector)
 			b.status = Deleted
 			return nil
 		}
 	}
 
 	if b.cond != nil {
-		if ok, err := b.cond.Matches(c); err != nil {
+		r, err := cond.Evaluate(b.cond, c)
+		if err != nil {
 			return err
-		} else if !ok {
+		}
+		b.outcome = newConditionOutcome(b)
+		b.outcome.Matched = r.Matched
+		b.outcome.Result = r
+		if !r.Matched {
 			b.status = Deleted
 			return nil
 		}
@@ -476,21 +659,48 @@ func (c *container) resolveBean(b *BeanDefinition) error {
 	return nil
 }
 
-// wireTag 注入语法的 tag 分解式，字符串形式的完整格式为 TypeName:BeanName? 。
-// 注入语法的字符串表示形式分为三个部分，TypeName 是原始类型的全限定名，BeanName
-// 是 bean 注册时设置的名称，? 表示注入结果允许为空。
+// wireTag 注入语法的 tag 分解式，字符串形式的完整格式为 TypeName:BeanName?[Labels] 。
+// 注入语法的字符串表示形式分为四个部分，TypeName 是原始类型的全限定名，BeanName
+// 是 bean 注册时设置的名称，? 表示注入结果允许为空，Labels 是零个或多个 [key=value]
+// 或者 [qualifier] 形式的标签选择器，例如 *?[region=eu][fast] 。BeanName 为 *
+// 时匹配任意名称的 bean 。
 type wireTag struct {
 	typeName string
 	beanName string
+	labels   []labelSelector
 	nullable bool
 }
 
-func parseWireTag(str string) (tag wireTag) {
+// labelSelector 标签选择器，hasValue 为 false 时匹配具有该限定符或者该标签的 bean 。
+type labelSelector struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+func (s labelSelector) String() string {
+	if s.hasValue {
+		return "[" + s.key + "=" + s.value + "]"
+	}
+	return "[" + s.key + "]"
+}
+
+func parseWireTag(str string) (tag wireTag, err error) {
 
 	if str == "" {
 		return
 	}
 
+	if i := strings.IndexByte(str, '['); i >= 0 {
+		if tag.labels, tag.nullable, err = parseLabels(str[i:]); err != nil {
+			return wireTag{}, fmt.Errorf("invalid bean selector %q: %w", str, err)
+		}
+		str = str[:i]
+		if str == "" {
+			return
+		}
+	}
+
 	if n := len(str) - 1; str[n] == '?' {
 		tag.nullable = true
 		str = str[:n]
@@ -507,6 +717,37 @@ func parseWireTag(str string) (tag wireTag) {
 	return
 }
 
+// parseLabels 解析 [key=value][qualifier] 形式的标签选择器，末尾可以有 ? 。
+func parseLabels(str string) (labels []labelSelector, nullable bool, err error) {
+	if n := len(str) - 1; str[n] == '?' {
+		nullable = true
+		str = str[:n]
+	}
+	for str != "" {
+		end := strings.IndexByte(str, ']')
+		if str[0] != '[' || end < 0 {
+			return nil, false, errors.New("label should be [key=value] or [qualifier]")
+		}
+		s := strings.TrimSpace(str[1:end])
+		str = str[end+1:]
+		var l labelSelector
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			l = labelSelector{
+				key:      strings.TrimSpace(s[:i]),
+				value:    strings.TrimSpace(s[i+1:]),
+				hasValue: true,
+			}
+		} else {
+			l = labelSelector{key: s}
+		}
+		if l.key == "" {
+			return nil, false, errors.New("label should be [key=value] or [qualifier]")
+		}
+		labels = append(labels, l)
+	}
+	return
+}
+
 func (tag wireTag) String() string {
 	b := bytes.NewBuffer(nil)
 	if tag.typeName != "" {
@@ -517,10 +758,30 @@ func (tag wireTag) String() string {
 	if tag.nullable {
 		b.WriteString("?")
 	}
+	for _, l := range tag.labels {
+		b.WriteString(l.String())
+	}
 	return b.String()
 }
 
-func toWireTag(selector util.BeanSelector) wireTag {
+// match 返回 bean 是否符合 tag 的类型、名称和标签。
+func (tag wireTag) match(b *BeanDefinition) bool {
+	beanName := tag.beanName
+	if beanName == "*" {
+		beanName = ""
+	}
+	if !b.Match(tag.typeName, beanName) {
+		return false
+	}
+	for _, l := range tag.labels {
+		if !b.hasLabel(l) {
+			return false
+		}
+	}
+	return true
+}
+
+func toWireTag(selector util.BeanSelector) (wireTag, error) {
 	switch s := selector.(type) {
 	case string:
 		return parseWireTag(s)
@@ -545,33 +806,64 @@ func toWireString(tags []wireTag) string {
 }
 
 // findBean 查找符合条件的 bean 对象，注意该函数只能保证返回的 bean 是有效的，
-// 即未被标记为删除的，而不能保证已经完成属性绑定和依赖注入。
+// 即未被标记为删除的，而不能保证已经完成属性绑定和依赖注入。当前容器中找不到时
+// 从父容器中查找。
 func (c *container) findBean(selector util.BeanSelector) ([]*BeanDefinition, error) {
+	beans, err := c.findLocalBean(selector)
+	if err != nil {
+		return nil, err
+	}
+	if len(beans) == 0 && c.parent != nil {
+		return c.parent.findBean(selector)
+	}
+	return beans, nil
+}
 
-	finder := func(fn func(*BeanDefinition) bool) ([]*BeanDefinition, error) {
-		var result []*BeanDefinition
-		for _, b := range c.beans {
-			if b.status == Resolving || b.status == Deleted || !fn(b) {
-				continue
-			}
-			if err := c.resolveBean(b); err != nil {
-				return nil, err
-			}
-			if b.status == Deleted {
-				continue
-			}
-			result = append(result, b)
+func (c *container) findLocalBean(selector util.BeanSelector) ([]*BeanDefinition, error) {
+
+	match, err := beanMatcher(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*BeanDefinition
+	for _, b := range c.beans {
+		if b.status == Resolving || b.status == Deleted || !match(b) {
+			continue
+		}
+		if err := c.resolveBean(b); err != nil {
+			return nil, err
+		}
+		if b.status == Deleted {
+			continue
 		}
-		return result, nil
+		result = append(result, b)
 	}
+	return result, nil
+}
+
+// parentSelector 返回 method bean 的 parent bean 选择器。
+func parentSelector(b *BeanDefinition) util.BeanSelector {
+	selector, ok := b.f.Arg(0)
+	if !ok || selector == "" {
+		selector, _ = b.f.In(0)
+	}
+	return selector
+}
+
+// beanMatcher 返回判断 bean 是否符合选择器的函数，不考虑 bean 的状态。
+func beanMatcher(selector util.BeanSelector) (func(*BeanDefinition) bool, error) {
 
 	var t reflect.Type
 	switch st := selector.(type) {
 	case string, BeanDefinition, *BeanDefinition:
-		tag := toWireTag(selector)
-		return finder(func(b *BeanDefinition) bool {
-			return b.Match(tag.typeName, tag.beanName)
-		})
+		tag, err := toWireTag(selector)
+		if err != nil {
+			return nil, err
+		}
+		return func(b *BeanDefinition) bool {
+			return tag.match(b)
+		}, nil
 	case reflect.Type:
 		t = st
 	default:
@@ -584,7 +876,7 @@ func (c *container) findBean(selector util.BeanSelector) ([]*BeanDefinition, err
 		}
 	}
 
-	return finder(func(b *BeanDefinition) bool {
+	return func(b *BeanDefinition) bool {
 		if b.Type() == t {
 			return true
 		}
@@ -594,7 +886,7 @@ func (c *container) findBean(selector util.BeanSelector) ([]*BeanDefinition, err
 			}
 		}
 		return false
-	})
+	}, nil
 }
 
 // wireBean 对 bean 进行属性绑定和依赖注入，同时追踪其注入路径。如果 bean 有初始
@@ -611,6 +903,11 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		return nil
 	}
 
+	// 非 singleton 作用域的 bean 在注入或者获取时才创建实例。
+	if !b.isSingleton() {
+		return nil
+	}
+
 	haveDestroy := false
 
 	defer func() {
@@ -620,7 +917,7 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 	}()
 
 	// 记录注入路径上的销毁函数及其执行的先后顺序。
-	if _, ok := b.Interface().(BeanDestroy); ok || b.destroy != nil {
+	if isBeanDestroy(b.Interface()) || b.destroy != nil {
 		haveDestroy = true
 		d := stack.saveDestroyer(b)
 		if i := stack.destroyers.Back(); i != nil {
@@ -629,6 +926,10 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		stack.destroyers.PushBack(b)
 	}
 
+	if err := c.waitBean(b, stack); err != nil {
+		return err
+	}
+
 	stack.pushBack(b)
 
 	if b.status == Creating && b.f != nil {
@@ -644,6 +945,7 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 	}
 
 	b.status = Creating
+	defer c.beginStep(stack, b.ID(), b.ID())()
 
 	// 对当前 bean 的间接依赖项进行注入。
 	for _, s := range b.depends {
@@ -652,6 +954,7 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 			return err
 		}
 		for _, d := range beans {
+			c.addEdge(stack, d, DependsEdge, "")
 			err = c.wireBean(d, stack)
 			if err != nil {
 				return err
@@ -659,13 +962,26 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		}
 	}
 
-	v, err := c.getBeanValue(b, stack)
+	v, err := c.getBeanValue(b, b.Value(), stack)
 	if err != nil {
 		return err
 	}
 
 	b.status = Created
 
+	if err = c.initBeanValue(b, b.Value(), v, stack); err != nil {
+		return err
+	}
+
+	b.status = Wired
+	stack.popBack()
+	return nil
+}
+
+// initBeanValue 对 bean 的值进行依赖注入，然后执行其初始化函数。slot 是 bean
+// 的存储单元，v 是 slot 中保存的可以进行注入的原始值。
+func (c *container) initBeanValue(b *BeanDefinition, slot, v reflect.Value, stack *wiringStack) error {
+
 	t := v.Type()
 	for _, typ := range b.exports {
 		if !t.Implements(typ) {
@@ -673,55 +989,205 @@ func (c *container) wireBean(b *BeanDefinition, stack *wiringStack) error {
 		}
 	}
 
-	err = c.wireBeanValue(v, t, stack)
+	endStep := c.beginStep(stack, "wiring", "")
+	err := c.wireBeanValue(v, t, stack)
+	endStep()
+	if err != nil {
+		return err
+	}
+
+	defer c.beginStep(stack, "init", "")()
+
+	err = c.postProcess(b, slot, BeanPostProcessor.BeforeInit)
 	if err != nil {
 		return err
 	}
 
 	if b.init != nil {
 		fnValue := reflect.ValueOf(b.init)
-		out := fnValue.Call([]reflect.Value{b.Value()})
+		var out []reflect.Value
+		c.withoutLock(func() { out = fnValue.Call([]reflect.Value{slot}) })
 		if len(out) > 0 && !out[0].IsNil() {
 			return out[0].Interface().(error)
 		}
 	}
 
-	if f, ok := b.Interface().(BeanInit); ok {
-		if err = f.OnInit(c); err != nil {
+	if f, ok := slot.Interface().(BeanInit); ok {
+		c.withoutLock(func() { err = f.OnInit(c) })
+		if err != nil {
 			return err
 		}
 	}
 
-	b.status = Wired
-	stack.popBack()
+	return c.postProcess(b, slot, BeanPostProcessor.AfterInit)
+}
+
+type postProcessFunc func(p BeanPostProcessor, bean interface{}, beanName string) (interface{}, error)
+
+// postProcess 依次执行 BeanPostProcessor 的处理函数，处理函数的返回值会替换
+// 存储单元 slot 中保存的 bean 。
+func (c *container) postProcess(b *BeanDefinition, slot reflect.Value, fn postProcessFunc) error {
+	for _, p := range c.processors {
+		var (
+			i   interface{}
+			err error
+		)
+		c.withoutLock(func() { i, err = fn(p, slot.Interface(), b.BeanName()) })
+		if err != nil {
+			return err
+		}
+		if i == nil {
+			return fmt.Errorf("%s post processor returns nil", b)
+		}
+		v := reflect.ValueOf(i)
+		if !v.Type().AssignableTo(slot.Type()) {
+			return fmt.Errorf("%s post processor returns %s which is not assignable to %s", b, v.Type(), slot.Type())
+		}
+		slot.Set(v)
+	}
 	return nil
 }
 
+// createBean 为非 singleton 作用域的 bean 创建新的实例并完成属性绑定和依赖注入，
+// 新实例的销毁由其所在的 Scope 负责。
+func (c *container) createBean(b *BeanDefinition, stack *wiringStack) (reflect.Value, error) {
+
+	for _, x := range stack.beans {
+		if x == b {
+			stack.pushBack(b)
+			return reflect.Value{}, errors.New("found circle autowire")
+		}
+	}
+
+	stack.pushBack(b)
+
+	for _, s := range b.depends {
+		beans, err := c.findBean(s)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		for _, d := range beans {
+			c.addEdge(stack, d, DependsEdge, "")
+			if err = c.wireBean(d, stack); err != nil {
+				return reflect.Value{}, err
+			}
+		}
+	}
+
+	slot, err := b.newValue()
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	v, err := c.getBeanValue(b, slot, stack)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+
+	if err = c.initBeanValue(b, slot, v, stack); err != nil {
+		return reflect.Value{}, err
+	}
+
+	stack.popBack()
+	return slot, nil
+}
+
+// scopedFactory 为 Scope 提供创建和销毁 bean 实例的能力。
+type scopedFactory struct {
+	c     *container
+	b     *BeanDefinition
+	stack *wiringStack
+}
+
+func (f *scopedFactory) Create() (interface{}, error) {
+	v, err := f.c.createBean(f.b, f.stack)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+func (f *scopedFactory) Destroy(i interface{}) {
+	if err := destroyBeanValue(f.c.ctx, reflect.ValueOf(i), f.b.destroy); err != nil {
+		f.c.logger.Error(err)
+	}
+}
+
+func (f *scopedFactory) boundKeys() []string {
+	return f.stack.keys[f.b]
+}
+
+// getScopedBean 从 bean 所在的 Scope 中获取 bean 的实例。
+func (c *container) getScopedBean(b *BeanDefinition, stack *wiringStack) (reflect.Value, error) {
+	s, ok := c.scopes[b.scope]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("scope %q not found, %s", b.scope, b)
+	}
+	ctx := stack.ctx
+	if ctx == nil {
+		ctx = c.ctx
+	}
+	i, err := s.Get(ctx, b.ID(), &scopedFactory{c: c, b: b, stack: stack})
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return reflect.ValueOf(i), nil
+}
+
+// getBeanInstance 返回 bean 用于注入的实例，非 singleton 作用域的 bean 从其
+// 所在的 Scope 中获取实例。
+func (c *container) getBeanInstance(b *BeanDefinition, stack *wiringStack) (reflect.Value, error) {
+	if b.isSingleton() {
+		return b.Value(), nil
+	}
+	return c.getScopedBean(b, stack)
+}
+
 type argContext struct {
 	c     *container
 	stack *wiringStack
 }
 
+func (a *argContext) Has(key string) bool {
+	defer a.c.lock()()
+	return a.c.p.Has(key)
+}
+
 func (a *argContext) Matches(c cond.Condition) (bool, error) {
+	defer a.c.lock()()
 	return c.Matches(a.c)
 }
 
 func (a *argContext) Bind(v reflect.Value, tag string) error {
+	defer a.c.lock()()
+	if t, err := conf.ParseTag(tag); err == nil {
+		a.stack.bindKey(t.Key)
+	}
 	return a.c.p.Bind(v, conf.Tag(tag))
 }
 
 func (a *argContext) Wire(v reflect.Value, tag string) error {
+	defer a.c.lock()()
+	a.stack.field = ""
 	return a.c.wireByTag(v, tag, a.stack)
 }
 
-// getBeanValue 获取 bean 的值，如果是构造函数 bean 则执行其构造函数然后返回执行结果。
-func (c *container) getBeanValue(b *BeanDefinition, stack *wiringStack) (reflect.Value, error) {
+// getBeanValue 获取 bean 的值，如果是构造函数 bean 则执行其构造函数然后将执行
+// 结果保存到存储单元 slot 中。
+func (c *container) getBeanValue(b *BeanDefinition, slot reflect.Value, stack *wiringStack) (reflect.Value, error) {
 
 	if b.f == nil {
-		return b.Value(), nil
+		return slot, nil
 	}
 
-	out, err := b.f.Call(&argContext{c: c, stack: stack})
+	// 执行构造函数时释放容器的锁，构造函数的参数通过 argContext 重新获取锁后注入。
+	var (
+		out []reflect.Value
+		err error
+	)
+	endStep := c.beginStep(stack, "constructor", "")
+	c.withoutLock(func() { out, err = b.f.Call(&argContext{c: c, stack: stack}) })
+	endStep()
 	if err != nil {
 		return reflect.Value{}, err /* fmt.Errorf("%s:%s return error: %v", b.getClass(), b.ID(), err) */
 	}
@@ -732,19 +1198,21 @@ func (c *container) getBeanValue(b *BeanDefinition, stack *wiringStack) (reflect
 		if !val.IsNil() && val.Kind() == reflect.Interface && util.IsValueType(val.Elem().Type()) {
 			v := reflect.New(val.Elem().Type())
 			v.Elem().Set(val.Elem())
-			b.Value().Set(v)
+			slot.Set(v)
 		} else {
-			b.Value().Set(val)
+			slot.Set(val)
 		}
 	} else {
-		b.Value().Elem().Set(val)
+		p := reflect.New(val.Type())
+		p.Elem().Set(val)
+		slot.Set(p)
 	}
 
-	if b.Value().IsNil() {
+	if slot.IsNil() {
 		return reflect.Value{}, fmt.Errorf("%s:%q return nil", b.getClass(), b.FileLine())
 	}
 
-	v := b.Value()
+	v := slot
 	// 结果以接口类型返回时需要将原始值取出来才能进行注入。
 	if b.Type().Kind() == reflect.Interface {
 		v = v.Elem()
@@ -813,6 +1281,7 @@ func (c *container) wireStruct(v reflect.Value, t reflect.Type, opt conf.BindPar
 				if ft.Type == contextType {
 					c.ContextAware = true
 				}
+				stack.field = fieldPath
 				if err := c.wireByTag(fv, tag, stack); err != nil {
 					return fmt.Errorf("%q wired error: %w", fieldPath, err)
 				}
@@ -836,6 +1305,7 @@ func (c *container) wireStruct(v reflect.Value, t reflect.Type, opt conf.BindPar
 					return err
 				}
 			} else {
+				stack.bindKey(subParam.Key)
 				err := c.p.BindValue(fv.Addr(), subParam)
 				if err != nil {
 					return err
@@ -871,7 +1341,11 @@ func (c *container) wireByTag(v reflect.Value, tag string, stack *wiringStack) e
 	var tags []wireTag
 	if tag != "?" {
 		for _, s := range strings.Split(tag, ",") {
-			tags = append(tags, toWireTag(s))
+			t, err := toWireTag(s)
+			if err != nil {
+				return err
+			}
+			tags = append(tags, t)
 		}
 	}
 	return c.autowire(v, tags, tag == "?", stack)
@@ -899,7 +1373,12 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 		return fmt.Errorf("receiver must be ref type, bean:%q", tag)
 	}
 
+	field := stack.field
 	t := v.Type()
+	if t == scopedProxyType {
+		return c.getScopedProxy(v, tag)
+	}
+
 	if !util.IsBeanReceiver(t) {
 		return fmt.Errorf("%s is not valid receiver type", t.String())
 	}
@@ -909,14 +1388,14 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 		if b.status == Deleted {
 			continue
 		}
-		if !b.Match(tag.typeName, tag.beanName) {
+		if !tag.match(b) {
 			continue
 		}
 		foundBeans = append(foundBeans, b)
 	}
 
 	// 指定 bean 名称时通过名称获取，防止未通过 Export 方法导出接口。
-	if t.Kind() == reflect.Interface && tag.beanName != "" {
+	if t.Kind() == reflect.Interface && tag.beanName != "" && tag.beanName != "*" {
 		for _, b := range c.beansByName[tag.beanName] {
 			if b.status == Deleted {
 				continue
@@ -924,7 +1403,7 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 			if !b.Type().AssignableTo(t) {
 				continue
 			}
-			if !b.Match(tag.typeName, tag.beanName) {
+			if !tag.match(b) {
 				continue
 			}
 
@@ -943,6 +1422,10 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 	}
 
 	if len(foundBeans) == 0 {
+		if c.parent != nil {
+			defer c.parent.lock()()
+			return c.parent.getBean(v, tag, newWiringStack(c.parent.logger))
+		}
 		if tag.nullable {
 			return nil
 		}
@@ -983,13 +1466,88 @@ func (c *container) getBean(v reflect.Value, tag wireTag, stack *wiringStack) er
 		result = foundBeans[0]
 	}
 
-	// 确保找到的 bean 已经完成依赖注入。
-	err := c.wireBean(result, stack)
+	c.addEdge(stack, result, "", field)
+
+	// 确保找到的 bean 已经完成依赖注入，注入代理对象的 lazy bean 除外。
+	_, lazy, err := c.getLazyProxy(result, t)
+	if err != nil {
+		return err
+	}
+	if !lazy {
+		if err = c.wireBean(result, stack); err != nil {
+			return err
+		}
+	}
+
+	val, err := c.getInjectValue(result, t, stack)
 	if err != nil {
 		return err
 	}
 
-	v.Set(result.Value())
+	v.Set(val)
+	return nil
+}
+
+// getInjectValue 返回 bean 注入到 t 类型的接收者的值，refresh 作用域的 bean 注
+// 入的是代理对象，代理对象在 bean 重新创建后会调用新的实例，lazy bean 注入的也
+// 是代理对象，代理对象在第一次调用时创建 bean 。
+func (c *container) getInjectValue(b *BeanDefinition, t reflect.Type, stack *wiringStack) (reflect.Value, error) {
+	if p, ok, err := c.getLazyProxy(b, t); err != nil || ok {
+		return p, err
+	}
+	if b.scope == RefreshScope {
+		p, ok, err := c.getRefreshProxy(b, t)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if ok {
+			return p, nil
+		}
+		// 刷新过程中注入的实例不会随属性变化而重新创建，运行时获取的是当前的实例。
+		if c.state != Refreshed {
+			return reflect.Value{}, fmt.Errorf("refresh scope bean should be injected into an interface registered by RegisterProxy, %s can't be injected into %s", b, t)
+		}
+	}
+	val, err := c.getBeanInstance(b, stack)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	return c.getProxy(b, t, val)
+}
+
+// getScopedProxy 获取 tag 对应的 bean 的代理然后赋值给 v，tag 不能为空。
+func (c *container) getScopedProxy(v reflect.Value, tag wireTag) error {
+
+	if tag.typeName == "" && tag.beanName == "" && len(tag.labels) == 0 {
+		return fmt.Errorf("bean name or type should be specified for %s", scopedProxyType)
+	}
+
+	var foundBeans []*BeanDefinition
+	for _, b := range c.beans {
+		if b.status == Deleted || !tag.match(b) {
+			continue
+		}
+		foundBeans = append(foundBeans, b)
+	}
+
+	if len(foundBeans) == 0 {
+		if tag.nullable {
+			return nil
+		}
+		return fmt.Errorf("can't find bean, bean:%q type:%q", tag, scopedProxyType)
+	}
+
+	if len(foundBeans) > 1 {
+		msg := fmt.Sprintf("found %d beans, bean:%q type:%q [", len(foundBeans), tag, scopedProxyType)
+		for _, b := range foundBeans {
+			msg += "( " + b.String() + " ), "
+		}
+		msg = msg[:len(msg)-2] + "]"
+		return errors.New(msg)
+	}
+
+	c.scopedProxy = true
+	v.Set(reflect.ValueOf(&ScopedProxy{c: c, b: foundBeans[0]}))
 	return nil
 }
 
@@ -998,7 +1556,7 @@ func filterBean(beans []*BeanDefinition, tag wireTag, t reflect.Type) (int, erro
 
 	var found []int
 	for i, b := range beans {
-		if b.Match(tag.typeName, tag.beanName) {
+		if tag.match(b) {
 			found = append(found, i)
 		}
 	}
@@ -1068,23 +1626,52 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, nullable bool,
 			beforeAny []*BeanDefinition
 		)
 
-		foundAny := false
+		var (
+			anyTag   wireTag
+			foundAny bool
+			missing  error
+		)
 		for _, item := range tags {
 
-			// 是否遇到了"无序"标记
+			// 是否遇到了"无序"标记，带有标签时只收集符合标签的其余 bean 。
 			if item.beanName == "*" {
 				if foundAny {
 					return fmt.Errorf("more than one * in collection %q", tags)
 				}
 				foundAny = true
+				anyTag = item
+				continue
+			}
+
+			// 只有标签的选择器收集所有符合标签的 bean 。
+			if item.typeName == "" && item.beanName == "" && len(item.labels) > 0 {
+				var rest []*BeanDefinition
+				for _, b := range beans {
+					if !item.match(b) {
+						rest = append(rest, b)
+					} else if foundAny {
+						afterAny = append(afterAny, b)
+					} else {
+						beforeAny = append(beforeAny, b)
+					}
+				}
+				beans = rest
 				continue
 			}
 
-			index, err := filterBean(beans, item, et)
+			// 有父容器时先在当前容器中查找，没有找到任何 bean 时再从父容器中查找。
+			tag := item
+			if c.parent != nil {
+				tag.nullable = true
+			}
+			index, err := filterBean(beans, tag, et)
 			if err != nil {
 				return err
 			}
 			if index < 0 {
+				if !item.nullable && missing == nil {
+					missing = fmt.Errorf("can't find bean, bean:%q type:%q", item, et)
+				}
 				continue
 			}
 
@@ -1099,7 +1686,11 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, nullable bool,
 		}
 
 		if foundAny {
-			anyBeans = append(anyBeans, beans...)
+			for _, b := range beans {
+				if anyTag.match(b) {
+					anyBeans = append(anyBeans, b)
+				}
+			}
 		}
 
 		n := len(beforeAny) + len(anyBeans) + len(afterAny)
@@ -1108,9 +1699,34 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, nullable bool,
 		arr = append(arr, anyBeans...)
 		arr = append(arr, afterAny...)
 		beans = arr
+
+		if missing != nil && len(beans) > 0 {
+			return missing
+		}
+	}
+
+	// 自动模式合并父容器中的 bean，它们排在当前容器的 bean 之后，map 中同名的 bean
+	// 以当前容器为准；指派模式和获取单个 bean 一样，只有当前容器中没有找到任何 bean
+	// 时才从父容器中查找。
+	var parent reflect.Value
+	if c.parent != nil {
+		if len(tags) > 0 {
+			if len(beans) == 0 {
+				defer c.parent.lock()()
+				return c.parent.collectBeans(v, tags, nullable, newWiringStack(c.parent.logger))
+			}
+		} else {
+			parent = reflect.New(t).Elem()
+			unlock := c.parent.lock()
+			err := c.parent.collectBeans(parent, nil, true, newWiringStack(c.parent.logger))
+			unlock()
+			if err != nil {
+				return err
+			}
+		}
 	}
 
-	if len(beans) == 0 && !nullable {
+	if len(beans) == 0 && (!parent.IsValid() || parent.Len() == 0) && !nullable {
 		if len(tags) == 0 {
 			return fmt.Errorf("no beans collected for %q", toWireString(tags))
 		}
@@ -1122,8 +1738,17 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, nullable bool,
 		return nil
 	}
 
+	field := stack.field
 	for _, b := range beans {
-		if err := c.wireBean(b, stack); err != nil {
+		c.addEdge(stack, b, "", field)
+		_, lazy, err := c.getLazyProxy(b, et)
+		if err != nil {
+			return err
+		}
+		if lazy {
+			continue
+		}
+		if err = c.wireBean(b, stack); err != nil {
 			return err
 		}
 	}
@@ -1131,43 +1756,72 @@ func (c *container) collectBeans(v reflect.Value, tags []wireTag, nullable bool,
 	var ret reflect.Value
 	switch t.Kind() {
 	case reflect.Slice:
-		sort.Sort(byOrder(beans))
+		sort.Stable(byOrder(beans))
 		ret = reflect.MakeSlice(t, 0, 0)
 		for _, b := range beans {
-			ret = reflect.Append(ret, b.Value())
+			val, err := c.getInjectValue(b, et, stack)
+			if err != nil {
+				return err
+			}
+			ret = reflect.Append(ret, val)
 		}
 	case reflect.Map:
 		ret = reflect.MakeMap(t)
 		for _, b := range beans {
-			ret.SetMapIndex(reflect.ValueOf(b.name), b.Value())
+			val, err := c.getInjectValue(b, et, stack)
+			if err != nil {
+				return err
+			}
+			ret.SetMapIndex(reflect.ValueOf(b.name), val)
+		}
+	}
+
+	if parent.IsValid() {
+		switch t.Kind() {
+		case reflect.Slice:
+			ret = reflect.AppendSlice(ret, parent)
+		case reflect.Map:
+			for _, k := range parent.MapKeys() {
+				if !ret.MapIndex(k).IsValid() {
+					ret.SetMapIndex(k, parent.MapIndex(k))
+				}
+			}
 		}
 	}
+
 	v.Set(ret)
 	return nil
 }
 
 // Close 关闭容器，此方法必须在 Refresh 之后调用。该方法会触发 ctx 的 Done 信
 // 号，然后等待所有 goroutine 结束，最后按照被依赖先销毁的原则执行所有的销毁函数。
+// 关闭子容器只销毁子容器自己的 bean，父容器需要在所有子容器关闭之后关闭。设置了
+// spring.shutdown.timeout 时超时后不再等待，关闭过程中发生的错误只打印日志，
+// App 将这些错误作为 Run 的返回值。
 func (c *container) Close() {
-
-	c.cancel()
-	c.wg.Wait()
-
-	c.logger.Info("goroutines exited")
-
-	for _, f := range c.destroyers {
-		f()
+	ctx, cancel, err := shutdownContext(c.p.Get(SpringShutdownTimeout))
+	if err != nil {
+		c.logger.Error(err)
+		ctx, cancel = context.WithCancel(context.Background())
 	}
-
-	c.logger.Info("container closed")
+	defer cancel()
+	c.closeContext(ctx)
 }
 
 // Go 创建安全可等待的 goroutine，fn 要求的 ctx 对象由 IoC 容器提供，当 IoC 容
 // 器关闭时 ctx会 发出 Done 信号， fn 在接收到此信号后应当立即退出。
 func (c *container) Go(fn func(ctx context.Context)) {
+	c.goNamed(goroutineName(fn), fn)
+}
+
+// goNamed 创建名称为 name 的 goroutine，关闭容器超时的时候报告没有退出的
+// goroutine 的名称。
+func (c *container) goNamed(name string, fn func(ctx context.Context)) {
+	id := c.goroutines.add(name)
 	c.wg.Add(1)
 	go func() {
 		defer c.wg.Done()
+		defer c.goroutines.remove(id)
 		defer func() {
 			if r := recover(); r != nil {
 				c.logger.Panic(r)
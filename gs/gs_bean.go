@@ -73,6 +73,23 @@ type BeanDestroy interface {
 	OnDestroy()
 }
 
+// isBeanDestroy 返回 bean 是否实现了 BeanDestroy 或者 BeanDestroyWithContext 接口。
+func isBeanDestroy(i interface{}) bool {
+	switch i.(type) {
+	case BeanDestroy, BeanDestroyWithContext:
+		return true
+	}
+	return false
+}
+
+// BeanPostProcessor 在 bean 完成依赖注入之后、执行初始化函数的前后对 bean 进
+// 行处理，返回值会替换原来的 bean，因此可以用来包装或者替换 bean 。实现该接口的
+// bean 会在其他 bean 之前创建，然后按照 Order 的顺序处理其他所有的 bean 。
+type BeanPostProcessor interface {
+	BeforeInit(bean interface{}, beanName string) (interface{}, error)
+	AfterInit(bean interface{}, beanName string) (interface{}, error)
+}
+
 // BeanDefinition bean 元数据。
 type BeanDefinition struct {
 
@@ -87,15 +104,25 @@ type BeanDefinition struct {
 	line int    // 注册点所在行数
 
 	name    string              // 名称
+	scope   string              // 作用域
 	status  beanStatus          // 状态
 	primary bool                // 是否为主版本
+	lazy    bool                // 是否延迟创建
 	method  bool                // 是否为成员方法
 	cond    cond.Condition      // 判断条件
+	outcome *ConditionOutcome   // 条件的判断结果
 	order   float32             // 收集时的顺序
 	init    interface{}         // 初始化函数
 	destroy interface{}         // 销毁函数
 	depends []util.BeanSelector // 间接依赖项
 	exports []reflect.Type      // 导出的接口
+	tasks   []*ScheduledTask    // 定时任务
+	factory *BeanDefinition     // 产品 bean 的工厂
+
+	labels     map[string]string // 标签
+	qualifiers []string          // 限定符
+
+	replaces []util.BeanSelector // 替换的 bean
 }
 
 // Type 返回 bean 的类型。
@@ -143,11 +170,29 @@ func (d *BeanDefinition) FileLine() string {
 	return fmt.Sprintf("%s:%d", d.file, d.line)
 }
 
+// newValue 为非 singleton 作用域的 bean 创建新的存储单元，对象 bean 以注册时
+// 的对象为模板进行浅拷贝，构造函数 bean 的存储单元在调用构造函数后赋值。
+func (d *BeanDefinition) newValue() (reflect.Value, error) {
+	slot := reflect.New(d.t).Elem()
+	if d.f == nil {
+		if !util.IsStructPtr(d.t) {
+			return reflect.Value{}, fmt.Errorf("%s should be struct pointer in %s scope", d, d.ScopeName())
+		}
+		v := reflect.New(d.t.Elem())
+		v.Elem().Set(d.v.Elem())
+		slot.Set(v)
+	}
+	return slot, nil
+}
+
 // getClass 返回 bean 的类型描述。
 func (d *BeanDefinition) getClass() string {
 	if d.f == nil {
 		return "object bean"
 	}
+	if d.factory != nil {
+		return "product bean"
+	}
 	return "constructor bean"
 }
 
@@ -171,6 +216,40 @@ func (d *BeanDefinition) Match(typeName string, beanName string) bool {
 	return typeIsSame && nameIsSame
 }
 
+// Label 为 bean 设置标签，注入时可以通过 [key=value] 形式的选择器筛选 bean，例如
+// autowire:"*?[region=eu]" 。
+func (d *BeanDefinition) Label(key, value string) *BeanDefinition {
+	if d.labels == nil {
+		d.labels = make(map[string]string)
+	}
+	d.labels[key] = value
+	return d
+}
+
+// Qualifier 为 bean 添加限定符，注入时可以通过 [qualifier] 形式的选择器筛选 bean，
+// 例如 autowire:"[fast]" 。
+func (d *BeanDefinition) Qualifier(qualifiers ...string) *BeanDefinition {
+	d.qualifiers = append(d.qualifiers, qualifiers...)
+	return d
+}
+
+// hasLabel 返回 bean 是否符合标签选择器，没有值的选择器匹配同名的限定符或者标签。
+func (d *BeanDefinition) hasLabel(l labelSelector) bool {
+	if l.hasValue {
+		v, ok := d.labels[l.key]
+		return ok && v == l.value
+	}
+	if _, ok := d.labels[l.key]; ok {
+		return true
+	}
+	for _, q := range d.qualifiers {
+		if q == l.key {
+			return true
+		}
+	}
+	return false
+}
+
 // Name 设置 bean 的名称。
 func (d *BeanDefinition) Name(name string) *BeanDefinition {
 	d.name = name
@@ -195,12 +274,47 @@ func (d *BeanDefinition) DependsOn(selectors ...util.BeanSelector) *BeanDefiniti
 	return d
 }
 
+// Scope 设置 bean 的作用域，默认为 singleton 作用域。
+func (d *BeanDefinition) Scope(scope string) *BeanDefinition {
+	d.scope = scope
+	return d
+}
+
+// ScopeName 返回 bean 的作用域。
+func (d *BeanDefinition) ScopeName() string {
+	if d.scope == "" {
+		return SingletonScope
+	}
+	return d.scope
+}
+
+// isSingleton 返回 bean 是否为 singleton 作用域。
+func (d *BeanDefinition) isSingleton() bool {
+	return d.scope == "" || d.scope == SingletonScope
+}
+
 // Primary 设置 bean 为主版本。
 func (d *BeanDefinition) Primary() *BeanDefinition {
 	d.primary = true
 	return d
 }
 
+// Replaces 设置 bean 替换容器中符合选择器的其他 bean，例如应用替换 starter 模块
+// 提供的默认 bean，或者在测试中用 mock 对象替换真实的 bean 。只有 bean 本身有效
+// 时才会替换，被替换的 bean 标记为已删除，替换关系记录在日志和条件评估报告中。
+func (d *BeanDefinition) Replaces(selectors ...util.BeanSelector) *BeanDefinition {
+	d.replaces = append(d.replaces, selectors...)
+	return d
+}
+
+// Lazy 设置 bean 在第一次注入或者获取时才创建，容器刷新时只判断 bean 的有效性。
+// 注入到接口类型的字段时，如果接口通过 RegisterProxy 注册了代理工厂，则注入的
+// 是代理对象，bean 在第一次调用代理对象的方法时创建。
+func (d *BeanDefinition) Lazy() *BeanDefinition {
+	d.lazy = true
+	return d
+}
+
 // validLifeCycleFunc 判断是否是合法的用于 bean 生命周期控制的函数，生命周期函数
 // 的要求：只能有一个入参并且必须是 bean 的类型，没有返回值或者只返回 error 类型值。
 func validLifeCycleFunc(fnType reflect.Type, beanValue reflect.Value) bool {
@@ -222,13 +336,25 @@ func (d *BeanDefinition) Init(fn interface{}) *BeanDefinition {
 	panic(errors.New("init should be func(bean) or func(bean)error"))
 }
 
-// Destroy 设置 bean 的销毁函数。
+// Destroy 设置 bean 的销毁函数，销毁函数的第二个参数可以是关闭容器的 ctx，ctx
+// 在关闭超时后发出 Done 信号，销毁函数返回的 error 会汇总到关闭容器的结果中。
 func (d *BeanDefinition) Destroy(fn interface{}) *BeanDefinition {
-	if validLifeCycleFunc(reflect.TypeOf(fn), d.Value()) {
+	if validLifeCycleFunc(reflect.TypeOf(fn), d.Value()) || validDestroyFunc(reflect.TypeOf(fn), d.Value()) {
 		d.destroy = fn
 		return d
 	}
-	panic(errors.New("destroy should be func(bean) or func(bean)error"))
+	panic(errors.New("destroy should be func(bean) or func(bean)error or func(bean,ctx)error"))
+}
+
+// validDestroyFunc 判断是否是第二个参数为 context.Context 的销毁函数。
+func validDestroyFunc(fnType reflect.Type, beanValue reflect.Value) bool {
+	if !util.IsFuncType(fnType) {
+		return false
+	}
+	if fnType.NumIn() != 2 || !util.HasReceiver(fnType, beanValue) || !util.IsContextType(fnType.In(1)) {
+		return false
+	}
+	return util.ReturnNothing(fnType) || util.ReturnOnlyError(fnType)
 }
 
 // Export 设置 bean 的导出接口。
@@ -264,6 +390,11 @@ func (d *BeanDefinition) export(exports ...interface{}) error {
 	return nil
 }
 
+// beanNamer 为 bean 提供默认名称的对象。
+type beanNamer interface {
+	beanName() string
+}
+
 // NewBean 普通函数注册时需要使用 reflect.ValueOf(fn) 形式以避免和构造函数发生冲突。
 func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 
@@ -306,12 +437,13 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 		f, err = arg.Bind(objOrCtor, ctorArgs, skip)
 		util.Panic(err).When(err != nil)
 
+		// 构造函数返回值为值类型时 bean 的类型是其指针类型。
 		out0 := t.Out(0)
-		v = reflect.New(out0)
-		if util.IsBeanType(out0) {
-			v = v.Elem()
+		if !util.IsBeanType(out0) {
+			out0 = reflect.PtrTo(out0)
 		}
 
+		v = reflect.New(out0).Elem()
 		t = v.Type()
 		if !util.IsBeanType(t) {
 			panic(errors.New("bean must be ref type"))
@@ -333,6 +465,20 @@ func NewBean(objOrCtor interface{}, ctorArgs ...arg.Arg) *BeanDefinition {
 		panic(errors.New("bean should be *val but not *ref"))
 	}
 
+	// bean 的值保存在可以赋值的存储单元中，以便 BeanPostProcessor 替换 bean 。
+	if !v.CanSet() {
+		slot := reflect.New(t).Elem()
+		slot.Set(v)
+		v = slot
+	}
+
+	// 对象自己提供名称时使用该名称作为 bean 的默认名称，例如 Executor 。
+	if name == "" {
+		if n, ok := v.Interface().(beanNamer); ok {
+			name = n.beanName()
+		}
+	}
+
 	// Type.String() 一般返回 *pkg.Type 形式的字符串，
 	// 我们只取最后的类型名，如有需要请自定义 bean 名称。
 	if name == "" {
@@ -0,0 +1,64 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+)
+
+// NewChild 创建子容器，子容器拥有自己的 bean 和属性，在子容器中找不到的 bean 和
+// 属性会从父容器中查找，例如每个租户或者插件使用一个子容器，而基础设施的 bean 放
+// 在父容器中共享。子容器必须在父容器刷新之后刷新，关闭子容器只销毁子容器自己的
+// bean，父容器关闭时子容器的 ctx 也会发出 Done 信号。需要注意的是父容器刷新后如
+// 果释放了注入过程中使用的临时数据则无法再创建子容器，因此应当在父容器刷新之前创
+// 建子容器，或者通过注入的 Context 对象创建子容器。
+func (c *container) NewChild() Container {
+	if c.state == Refreshed && c.tempContainer == nil {
+		panic(errors.New("parent container has been cleared"))
+	}
+	child := New().(*container)
+	child.setParent(c)
+	return child
+}
+
+// setParent 设置容器的父容器，子容器的 ctx 派生自父容器的 ctx 。
+func (c *container) setParent(parent *container) {
+	c.cancel()
+	c.ctx, c.cancel = context.WithCancel(parent.ctx)
+	c.parent = parent
+	parent.hasChild = true
+}
+
+// inheritProperties 将父容器的属性复制到子容器中，子容器已经设置的属性优先。父
+// 容器的属性在子容器刷新之后发生的变化不会同步到子容器。
+func (c *container) inheritProperties() error {
+	if c.parent == nil {
+		return nil
+	}
+	if c.parent.state != Refreshed {
+		return errors.New("parent container should be refreshed first")
+	}
+	for _, key := range c.parent.p.Keys() {
+		if c.initProperties.Has(key) {
+			continue
+		}
+		// 子容器的属性和父容器的属性结构冲突时以子容器为准。
+		_ = c.initProperties.Set(key, c.parent.p.Get(key))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+// SpringConditionReport 是否在启动时打印条件判断报告。
+const SpringConditionReport = "spring.condition.report"
+
+// ConditionOutcome 记录 bean 的条件判断结果，用于解释 bean 为什么有效或者被删除。
+type ConditionOutcome struct {
+	ID       string       `json:"id"`
+	FileLine string       `json:"fileLine"`
+	Matched  bool         `json:"matched"`
+	Message  string       `json:"message,omitempty"` // 例如 parent bean 不存在
+	Result   *cond.Result `json:"result,omitempty"`  // 每个子条件的判断结果
+}
+
+func newConditionOutcome(b *BeanDefinition) *ConditionOutcome {
+	return &ConditionOutcome{ID: b.ID(), FileLine: b.FileLine()}
+}
+
+func (o *ConditionOutcome) String() string {
+	var buf strings.Builder
+	matched := "unmatched"
+	if o.Matched {
+		matched = "matched"
+	}
+	buf.WriteString(o.ID + " " + matched + " " + o.FileLine)
+	if o.Message != "" {
+		buf.WriteString("\n  " + o.Message)
+	}
+	if o.Result != nil {
+		for _, s := range strings.Split(o.Result.String(), "\n") {
+			buf.WriteString("\n  " + s)
+		}
+	}
+	return buf.String()
+}
+
+// ConditionReport 返回条件判断报告，包含所有设置了条件的 bean 以及因为 parent
+// bean 不存在而被删除的 bean，按照 bean 的 ID 排序。
+func (c *container) ConditionReport() []*ConditionOutcome {
+	return c.conditions
+}
+
+// conditionReport 收集 bean 的条件判断结果，如果开启了 spring.condition.report
+// 则打印条件判断报告。
+func (c *container) conditionReport() []*ConditionOutcome {
+
+	var report []*ConditionOutcome
+	for _, b := range c.beans {
+		if b.outcome != nil {
+			report = append(report, b.outcome)
+		}
+	}
+	report = append(report, c.moduleOutcomes...)
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].ID < report[j].ID
+	})
+
+	if ok, _ := strconv.ParseBool(c.p.Get(SpringConditionReport)); ok {
+		var buf strings.Builder
+		buf.WriteString("condition evaluation report:")
+		for _, o := range report {
+			buf.WriteString("\n" + o.String())
+		}
+		c.logger.Info(buf.String())
+	}
+	return report
+}
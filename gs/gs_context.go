@@ -79,6 +79,15 @@ func (c *container) Get(i interface{}, selectors ...util.BeanSelector) error {
 		return errors.New("i must be pointer")
 	}
 
+	var tags []wireTag
+	for _, s := range selectors {
+		tag, err := toWireTag(s)
+		if err != nil {
+			return err
+		}
+		tags = append(tags, tag)
+	}
+
 	stack := newWiringStack(c.logger)
 
 	defer func() {
@@ -87,11 +96,13 @@ func (c *container) Get(i interface{}, selectors ...util.BeanSelector) error {
 		}
 	}()
 
-	var tags []wireTag
-	for _, s := range selectors {
-		tags = append(tags, toWireTag(s))
-	}
-	return c.autowire(v.Elem(), tags, false, stack)
+	return c.runtimeWire(stack, func() error {
+		if err := c.autowire(v.Elem(), tags, false, stack); err != nil {
+			return err
+		}
+		c.saveLazyDestroyers(stack)
+		return nil
+	})
 }
 
 // Wire 如果传入的是 bean 对象，则对 bean 对象进行属性绑定和依赖注入，如果传入的
@@ -108,7 +119,9 @@ func (c *container) Wire(objOrCtor interface{}, ctorArgs ...arg.Arg) (interface{
 	}()
 
 	b := NewBean(objOrCtor, ctorArgs...)
-	err := c.wireBean(b, stack)
+	err := c.runtimeWire(stack, func() error {
+		return c.wireBean(b, stack)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,285 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Trigger 计算定时任务的下次执行时间。
+type Trigger interface {
+
+	// Next 返回 t 之后的下次执行时间，没有下次执行时间时返回零值。
+	Next(t time.Time) time.Time
+}
+
+// fixedRate 以固定的频率执行任务，不管上次执行是否结束。
+type fixedRate struct {
+	d time.Duration
+}
+
+func (r fixedRate) Next(t time.Time) time.Time {
+	return t.Add(r.d)
+}
+
+// fixedDelay 在上次执行结束之后间隔固定的时间再执行任务。
+type fixedDelay struct {
+	d time.Duration
+}
+
+func (r fixedDelay) Next(t time.Time) time.Time {
+	return t.Add(r.d)
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// ParseTrigger 解析定时任务的执行计划，支持以下几种形式:
+// 1. cron 表达式，5 个字段时依次为分、时、日、月、周，6 个字段时第一个字段为秒；
+// 2. @yearly、@monthly、@weekly、@daily、@hourly 等预定义的 cron 表达式；
+// 3. @every 10s，以固定的频率执行；
+// 4. @delay 10s，在上次执行结束之后间隔固定的时间再执行。
+func ParseTrigger(spec string) (Trigger, error) {
+
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("empty schedule spec")
+	}
+
+	if strings.HasPrefix(spec, "@every ") || strings.HasPrefix(spec, "@delay ") {
+		d, err := time.ParseDuration(strings.TrimSpace(spec[7:]))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid duration in schedule spec %q", spec)
+		}
+		if spec[1] == 'e' {
+			return fixedRate{d}, nil
+		}
+		return fixedDelay{d}, nil
+	}
+
+	if s, ok := cronMacros[spec]; ok {
+		return parseCron(s)
+	}
+	if spec[0] == '@' {
+		return nil, fmt.Errorf("unknown schedule spec %q", spec)
+	}
+	return parseCron(spec)
+}
+
+// cronField 是 cron 表达式的一个字段的取值范围。
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSecond = cronField{name: "second", min: 0, max: 59}
+	cronMinute = cronField{name: "minute", min: 0, max: 59}
+	cronHour   = cronField{name: "hour", min: 0, max: 23}
+	cronDom    = cronField{name: "day of month", min: 1, max: 31}
+	cronMonth  = cronField{name: "month", min: 1, max: 12, names: map[string]int{
+		"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+		"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+	}}
+	cronDow = cronField{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5, "SAT": 6,
+	}}
+)
+
+// cronTrigger 按照 cron 表达式执行任务，每个字段使用位图保存可以取的值。
+type cronTrigger struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool // 日或者周是否为 * 或者 ?
+}
+
+func parseCron(spec string) (*cronTrigger, error) {
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, fmt.Errorf("cron spec %q should have 5 or 6 fields", spec)
+	}
+
+	var (
+		r   cronTrigger
+		err error
+	)
+
+	parsers := []struct {
+		bits *uint64
+		f    cronField
+	}{
+		{&r.second, cronSecond},
+		{&r.minute, cronMinute},
+		{&r.hour, cronHour},
+		{&r.dom, cronDom},
+		{&r.month, cronMonth},
+		{&r.dow, cronDow},
+	}
+
+	for i, p := range parsers {
+		if *p.bits, err = p.f.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid cron spec %q: %w", spec, err)
+		}
+	}
+
+	// 周日既可以是 0 也可以是 7 。
+	if r.dow&(1<<7) != 0 {
+		r.dow |= 1
+	}
+	r.domStar = fields[3] == "*" || fields[3] == "?"
+	r.dowStar = fields[5] == "*" || fields[5] == "?"
+	return &r, nil
+}
+
+// parse 解析字段的值，支持 *、?、a、a-b、*/n、a/n、a-b/n 以及逗号分隔的列表。
+func (f cronField) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+
+		rangeStr, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q of %s", item, f.name)
+			}
+			rangeStr, step = item[:i], n
+		}
+
+		var start, end int
+		switch {
+		case rangeStr == "*" || rangeStr == "?":
+			start, end = f.min, f.max
+		case strings.IndexByte(rangeStr, '-') > 0:
+			i := strings.IndexByte(rangeStr, '-')
+			a, err := f.value(rangeStr[:i])
+			if err != nil {
+				return 0, err
+			}
+			b, err := f.value(rangeStr[i+1:])
+			if err != nil {
+				return 0, err
+			}
+			if a > b {
+				return 0, fmt.Errorf("invalid range %q of %s", rangeStr, f.name)
+			}
+			start, end = a, b
+		default:
+			a, err := f.value(rangeStr)
+			if err != nil {
+				return 0, err
+			}
+			start, end = a, a
+			if step > 1 {
+				end = f.max
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析字段的单个值，月和周可以使用英文缩写。
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToUpper(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid value %q of %s", s, f.name)
+	}
+	return v, nil
+}
+
+func (r *cronTrigger) matchDay(t time.Time) bool {
+	domMatch := r.dom&(1<<uint(t.Day())) != 0
+	dowMatch := r.dow&(1<<uint(t.Weekday())) != 0
+	// 日和周都有限制时只要满足其中一个即可，这和 Unix 的 cron 保持一致。
+	if !r.domStar && !r.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next 从 t 的下一秒开始逐级查找满足条件的月、日、时、分、秒，五年之内找不到时
+// 返回零值，例如 2 月 30 日。
+func (r *cronTrigger) Next(t time.Time) time.Time {
+
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	loc := t.Location()
+	limit := t.Year() + 5
+
+WRAP:
+	if t.Year() > limit {
+		return time.Time{}
+	}
+
+	for r.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+
+	for !r.matchDay(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+
+	for r.hour&(1<<uint(t.Hour())) == 0 {
+		t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+
+	for r.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+
+	for r.second&(1<<uint(t.Second())) == 0 {
+		t = t.Truncate(time.Second).Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+
+	return t
+}
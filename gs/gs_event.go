@@ -0,0 +1,117 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-spring/spring-base/util"
+)
+
+// EventListener 事件监听器，以 bean 的形式注册到容器中，容器通过 Publish 方法
+// 发布事件时将事件分发给监听该类型事件的所有监听器。
+type EventListener struct {
+	name  string
+	fn    reflect.Value
+	event reflect.Type
+	async bool
+}
+
+// NewEventListener 创建事件监听器，fn 的形式为 func(ctx context.Context, e *MyEvent) error，
+// fn 的第二个参数的类型决定了监听的事件类型，可以是接口类型。
+func NewEventListener(fn interface{}) *EventListener {
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || t.NumIn() != 2 || !util.IsContextType(t.In(0)) || !util.ReturnOnlyError(t) {
+		panic(errors.New("listener should be func(ctx context.Context, event)error"))
+	}
+	_, _, name := util.FileLine(fn)
+	return &EventListener{name: name, fn: reflect.ValueOf(fn), event: t.In(1)}
+}
+
+// Async 设置监听器异步接收事件，事件在容器管理的 goroutine 中分发。
+func (l *EventListener) Async() *EventListener {
+	l.async = true
+	return l
+}
+
+// Listen 注册事件监听器 bean，bean 的名称默认为监听函数的函数名，同一个函数多次
+// 注册时从第二次开始在函数名后面添加 #1、#2 等序号。需要注意的是该方法在注入开始
+// 后就不能再调用了。
+func (c *container) Listen(l *EventListener) *BeanDefinition {
+	name := l.name
+	if c.listenerNames == nil {
+		c.listenerNames = make(map[string]int)
+	}
+	if n := c.listenerNames[l.name]; n > 0 {
+		name = fmt.Sprintf("%s#%d", l.name, n)
+	}
+	c.listenerNames[l.name]++
+	return c.Accept(NewBean(reflect.ValueOf(l))).Name(name)
+}
+
+// accept 返回监听器是否监听事件 t 。
+func (l *EventListener) accept(t reflect.Type) bool {
+	if l.event.Kind() == reflect.Interface {
+		return t.Implements(l.event)
+	}
+	return t == l.event
+}
+
+func (l *EventListener) invoke(ctx context.Context, event reflect.Value) error {
+	out := l.fn.Call([]reflect.Value{reflect.ValueOf(ctx), event})
+	if err, _ := out[0].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Publish 发布事件，同步的监听器按照 bean 的 Order 顺序依次执行，如果某个监听器
+// 返回 error 则停止分发并返回该 error；异步的监听器在 Go 创建的 goroutine 中执行，
+// 返回的 error 只记录日志。容器开始关闭之后不再分发事件，直接返回 error 。
+func (c *container) Publish(event interface{}) error {
+
+	if event == nil {
+		return errors.New("event can't be nil")
+	}
+
+	if c.ctx.Err() != nil {
+		return errors.New("container is closing")
+	}
+
+	v := reflect.ValueOf(event)
+	for _, l := range c.listeners {
+		if !l.accept(v.Type()) {
+			continue
+		}
+		if l.async {
+			l := l
+			c.Go(func(ctx context.Context) {
+				if err := l.invoke(ctx, v); err != nil {
+					c.logger.Errorf("async listener of %s returns error: %v", v.Type(), err)
+				}
+			})
+			continue
+		}
+		if err := l.invoke(c.ctx, v); err != nil {
+			return fmt.Errorf("listener of %s returns error: %w", v.Type(), err)
+		}
+	}
+	return nil
+}
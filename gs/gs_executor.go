@@ -0,0 +1,323 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/go-spring/spring-base/log"
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/conf"
+)
+
+// 队列已满时 Executor 对新任务的处理策略。
+const (
+	RejectAbort      = "abort"       // Submit 返回 ErrRejected
+	RejectCallerRuns = "caller-runs" // 在调用 Submit 的 goroutine 中执行
+	RejectDiscard    = "discard"     // 丢弃任务，Future 返回 ErrRejected
+	RejectBlock      = "block"       // 等待队列空闲或者 Executor 关闭
+)
+
+// ErrRejected 表示任务被 Executor 拒绝执行。
+var ErrRejected = errors.New("task rejected")
+
+// ExecutorConfig 是 Executor 的配置，从 executor.<name> 前缀的属性中绑定。
+type ExecutorConfig struct {
+	Size            int    `value:"${size:=10}"`                // 工作 goroutine 的数量
+	Queue           int    `value:"${queue:=100}"`              // 等待队列的长度
+	RejectionPolicy string `value:"${rejection-policy:=abort}"` // 队列已满时的处理策略
+}
+
+// ExecutorStats 是 Executor 的运行统计。
+type ExecutorStats struct {
+	Size      int   // 工作 goroutine 的数量
+	Queued    int   // 正在排队的任务数
+	Active    int64 // 正在执行的任务数
+	Submitted int64 // 提交成功的任务数
+	Completed int64 // 执行结束的任务数，包括发生 panic 的任务
+	Failed    int64 // 发生 panic 的任务数
+	Rejected  int64 // 被拒绝的任务数
+}
+
+// Future 是提交给 Executor 的任务的执行结果。
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done 返回任务结束时关闭的通道。
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait 等待任务结束，任务发生 panic 或者被丢弃时返回 error，ctx 先结束时返回
+// ctx 的 error 。
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type executorTask struct {
+	fn     func(ctx context.Context)
+	future *Future
+}
+
+// Executor 固定数量的 goroutine 组成的工作池，以 bean 的形式注册到容器中，初
+// 始化时从 executor.<name> 前缀的属性中读取配置，例如 executor.io.size=8 。和
+// Go 不同，Executor 通过有界的队列和拒绝策略限制同时执行和等待的任务数。关闭容
+// 器时 Executor 不再接受新任务，在关闭超时之前执行完队列中的任务，超时后通过 ctx
+// 通知正在执行的任务退出。bean 的默认名称就是 Executor 的名称，因此注入时可以
+// 按名称区分不同的 Executor 。
+type Executor struct {
+	name     string
+	cfg      ExecutorConfig
+	logger   *log.Logger
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mutex    sync.RWMutex
+	queue    chan *executorTask
+	stopping chan struct{}
+	running  bool
+	wg       sync.WaitGroup
+
+	active    int64
+	submitted int64
+	completed int64
+	failed    int64
+	rejected  int64
+}
+
+// NewExecutor 创建名为 name 的 Executor 。
+func NewExecutor(name string) *Executor {
+	e := &Executor{name: name}
+	e.logger = log.GetLogger(util.TypeName(e))
+	return e
+}
+
+// Name 返回 Executor 的名称。
+func (e *Executor) Name() string {
+	return e.name
+}
+
+// beanName 使用 Executor 的名称作为 bean 的默认名称。
+func (e *Executor) beanName() string {
+	return e.name
+}
+
+// OnInit 绑定 Executor 的配置并启动工作 goroutine 。
+func (e *Executor) OnInit(ctx Context) error {
+
+	if err := ctx.Bind(&e.cfg, conf.Key("executor."+e.name)); err != nil {
+		return err
+	}
+
+	if e.cfg.Size <= 0 {
+		return fmt.Errorf("executor %s: size should be positive but got %d", e.name, e.cfg.Size)
+	}
+	if e.cfg.Queue < 0 {
+		return fmt.Errorf("executor %s: queue should not be negative but got %d", e.name, e.cfg.Queue)
+	}
+	switch e.cfg.RejectionPolicy {
+	case RejectAbort, RejectCallerRuns, RejectDiscard, RejectBlock:
+	default:
+		return fmt.Errorf("executor %s: unknown rejection policy %q", e.name, e.cfg.RejectionPolicy)
+	}
+
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	e.queue = make(chan *executorTask, e.cfg.Queue)
+	e.stopping = make(chan struct{})
+	e.running = true
+
+	for i := 0; i < e.cfg.Size; i++ {
+		e.wg.Add(1)
+		go func() {
+			defer e.wg.Done()
+			for t := range e.queue {
+				e.execute(t)
+			}
+		}()
+	}
+	return nil
+}
+
+// Submit 提交任务，fn 的 ctx 在 Executor 关闭超时后发出 Done 信号。队列已满时
+// 按照拒绝策略处理，Executor 没有运行或者拒绝策略为 abort 时返回 error 。
+func (e *Executor) Submit(fn func(ctx context.Context)) (*Future, error) {
+
+	e.mutex.RLock()
+	locked := true
+	defer func() {
+		if locked {
+			e.mutex.RUnlock()
+		}
+	}()
+
+	if !e.running {
+		atomic.AddInt64(&e.rejected, 1)
+		return nil, fmt.Errorf("executor %s is not running: %w", e.name, ErrRejected)
+	}
+
+	t := &executorTask{fn: fn, future: newFuture()}
+	select {
+	case e.queue <- t:
+		atomic.AddInt64(&e.submitted, 1)
+		return t.future, nil
+	default:
+	}
+
+	switch e.cfg.RejectionPolicy {
+	case RejectCallerRuns:
+		atomic.AddInt64(&e.submitted, 1)
+		// 在调用者的 goroutine 中执行任务之前释放读锁，否则 OnDestroy 需要等待任务
+		// 执行完才能获取写锁，而任务可能正在等待 OnDestroy 超时后发出的 Done 信号。
+		e.wg.Add(1)
+		e.mutex.RUnlock()
+		locked = false
+		defer e.wg.Done()
+		e.execute(t)
+		return t.future, nil
+	case RejectDiscard:
+		atomic.AddInt64(&e.rejected, 1)
+		e.logger.Warnf("executor %s discarded a task because the queue is full", e.name)
+		t.future.complete(ErrRejected)
+		return t.future, nil
+	case RejectBlock:
+		select {
+		case e.queue <- t:
+			atomic.AddInt64(&e.submitted, 1)
+			return t.future, nil
+		case <-e.stopping:
+			atomic.AddInt64(&e.rejected, 1)
+			return nil, fmt.Errorf("executor %s is stopping: %w", e.name, ErrRejected)
+		}
+	default:
+		atomic.AddInt64(&e.rejected, 1)
+		return nil, fmt.Errorf("executor %s queue is full: %w", e.name, ErrRejected)
+	}
+}
+
+// execute 执行任务，任务发生的 panic 记录日志并作为 Future 的 error 。
+func (e *Executor) execute(t *executorTask) {
+
+	atomic.AddInt64(&e.active, 1)
+	defer atomic.AddInt64(&e.active, -1)
+	defer atomic.AddInt64(&e.completed, 1)
+
+	var err error
+	defer func() { t.future.complete(err) }()
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddInt64(&e.failed, 1)
+			err = fmt.Errorf("executor %s task panic: %v", e.name, r)
+			e.logger.Panic(err)
+		}
+	}()
+	t.fn(e.ctx)
+}
+
+// Stats 返回 Executor 的运行统计。
+func (e *Executor) Stats() ExecutorStats {
+	return ExecutorStats{
+		Size:      e.cfg.Size,
+		Queued:    len(e.queue),
+		Active:    atomic.LoadInt64(&e.active),
+		Submitted: atomic.LoadInt64(&e.submitted),
+		Completed: atomic.LoadInt64(&e.completed),
+		Failed:    atomic.LoadInt64(&e.failed),
+		Rejected:  atomic.LoadInt64(&e.rejected),
+	}
+}
+
+// OnDestroy 关闭 Executor，不再接受新任务并等待队列中的任务执行完，ctx 超时后
+// 通知正在执行的任务退出，并通过 error 报告没有执行完的任务数。
+func (e *Executor) OnDestroy(ctx context.Context) error {
+
+	if e.stopping == nil {
+		return nil
+	}
+
+	// 先唤醒阻塞在 Submit 中的调用者，然后才能获取写锁。
+	select {
+	case <-e.stopping:
+		return nil // 已经关闭
+	default:
+		close(e.stopping)
+	}
+	e.mutex.Lock()
+	e.running = false
+	close(e.queue)
+	e.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(done)
+	}()
+
+	defer e.cancel()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s := e.Stats()
+		return fmt.Errorf("executor %s stopped with %d active and %d queued tasks", e.name, s.Active, s.Queued)
+	}
+}
+
+// Async 返回在 Executor 中异步执行 bean 方法的拦截器，methods 为空时作用于所有
+// 方法，例如 c.Intercept((*Mailer)(nil), e.Async("Send")) 。异步执行的方法立即
+// 返回零值，方法返回的 error 只记录日志，因此适用于没有返回值的方法。任务被拒绝
+// 时返回 ErrRejected，方法没有 error 类型的返回值时会 panic 。
+func (e *Executor) Async(methods ...string) Interceptor {
+	return func(inv Invocation) ([]interface{}, error) {
+		if len(methods) > 0 && !containsString(methods, inv.Method()) {
+			return inv.Proceed()
+		}
+		_, err := e.Submit(func(ctx context.Context) {
+			if _, err := inv.Proceed(); err != nil {
+				e.logger.Errorf("async method %s returns error: %v", inv.Method(), err)
+			}
+		})
+		return nil, err
+	}
+}
+
+func containsString(a []string, s string) bool {
+	for _, v := range a {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-spring/spring-base/util"
+)
+
+// FactoryBean 生产 bean 的 bean 。容器为实现了该接口的 bean 额外注册一个名称相同、
+// 类型为 ObjectType 的 bean，称为产品 bean，通过 ObjectType 注入时得到的是 Object
+// 的返回值，通过工厂自身的类型注入时得到的仍然是工厂。工厂在 Object 被调用之前完
+// 成注入，因此可以根据刷新时的属性决定生产的对象，例如为每个配置的服务地址注册一
+// 个工厂，每个工厂生产一个客户端。一个工厂需要生产多个产品时实现 MultiFactoryBean 。
+//
+// 需要注意的是 ObjectType 在注册阶段调用，构造函数 bean 调用时接收者是零值，因此
+// ObjectType 不能依赖工厂的状态；bean 的类型是接口时容器无法识别它是否为工厂。
+type FactoryBean interface {
+	Object() (interface{}, error)
+	ObjectType() reflect.Type
+}
+
+// MultiFactoryBean 生产多个同类型产品的工厂，容器为 ObjectNames 返回的每个名称
+// 注册一个产品 bean，产品 bean 的名称就是该名称，产品通过 ObjectOf 获取。例如一
+// 个工厂根据配置为固定的几个机房各生产一个客户端。
+//
+// ObjectNames 和 ObjectType 一样在注册阶段调用，不能依赖工厂注入的状态。同时实
+// 现了 FactoryBean 的工厂只按照 FactoryBean 处理。
+type MultiFactoryBean interface {
+	ObjectNames() []string
+	ObjectType() reflect.Type
+	ObjectOf(name string) (interface{}, error)
+}
+
+var (
+	factoryBeanType      = reflect.TypeOf((*FactoryBean)(nil)).Elem()
+	multiFactoryBeanType = reflect.TypeOf((*MultiFactoryBean)(nil)).Elem()
+	errorType            = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// registerProducts 为 beans 中的工厂注册产品 bean 。
+func (c *container) registerProducts(beans []*BeanDefinition) error {
+	for _, b := range beans {
+		products, err := newProductBeans(b)
+		if err != nil {
+			return err
+		}
+		for _, p := range products {
+			c.beans = append(c.beans, p)
+			c.registerBean(p)
+		}
+	}
+	return nil
+}
+
+// newProductBeans 创建工厂 b 的所有产品 bean，b 不是工厂时返回空。
+func newProductBeans(b *BeanDefinition) ([]*BeanDefinition, error) {
+
+	t := b.Type()
+	if t.Kind() == reflect.Interface {
+		return nil, nil
+	}
+
+	v := b.Value()
+	if b.f != nil {
+		v = reflect.Zero(t)
+	}
+
+	if t.Implements(factoryBeanType) {
+		objType := v.Interface().(FactoryBean).ObjectType()
+		p, err := newProductBean(b, b.name, objType, func(fv reflect.Value) (interface{}, error) {
+			return fv.Interface().(FactoryBean).Object()
+		})
+		if err != nil {
+			return nil, err
+		}
+		return []*BeanDefinition{p}, nil
+	}
+
+	if !t.Implements(multiFactoryBeanType) {
+		return nil, nil
+	}
+
+	f := v.Interface().(MultiFactoryBean)
+	objType := f.ObjectType()
+	names := make(map[string]bool)
+	var products []*BeanDefinition
+	for _, name := range f.ObjectNames() {
+		if name == "" || names[name] {
+			return nil, fmt.Errorf("object name %q of factory %s should be unique and non-empty", name, b)
+		}
+		names[name] = true
+		name := name
+		p, err := newProductBean(b, name, objType, func(fv reflect.Value) (interface{}, error) {
+			return fv.Interface().(MultiFactoryBean).ObjectOf(name)
+		})
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, nil
+}
+
+// newProductBean 创建工厂 b 的名称为 name 的产品 bean，产品 bean 的构造函数以
+// 工厂为参数并调用 object 获取产品。
+func newProductBean(b *BeanDefinition, name string, objType reflect.Type, object func(fv reflect.Value) (interface{}, error)) (*BeanDefinition, error) {
+
+	if objType == nil || !util.IsBeanType(objType) {
+		return nil, fmt.Errorf("object type %v of factory %s should be ref type", objType, b)
+	}
+
+	fnType := reflect.FuncOf([]reflect.Type{b.Type()}, []reflect.Type{objType, errorType}, false)
+	fn := reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		obj, err := object(in[0])
+		if err == nil {
+			err = checkProduct(obj, objType, b)
+		}
+		if err != nil {
+			return []reflect.Value{reflect.Zero(objType), reflect.ValueOf(&err).Elem()}
+		}
+		return []reflect.Value{reflect.ValueOf(obj), reflect.Zero(errorType)}
+	})
+
+	p := NewBean(fn.Interface(), b)
+	p.name = name
+	p.file = b.file
+	p.line = b.line
+	p.factory = b
+	return p, nil
+}
+
+// checkProduct 检查工厂生产的对象是否可以作为类型为 objType 的 bean 。
+func checkProduct(obj interface{}, objType reflect.Type, b *BeanDefinition) error {
+	if obj == nil {
+		return fmt.Errorf("factory %s returns nil object", b)
+	}
+	if t := reflect.TypeOf(obj); !t.AssignableTo(objType) {
+		return fmt.Errorf("factory %s returns %v but object type is %v", b, t, objType)
+	}
+	switch v := reflect.ValueOf(obj); v.Kind() {
+	case reflect.Ptr, reflect.Chan, reflect.Func, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return fmt.Errorf("factory %s returns nil object", b)
+		}
+	}
+	return nil
+}
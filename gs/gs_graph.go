@@ -0,0 +1,154 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+const (
+	FieldEdge   = "field"   // 通过 autowire 字段注入
+	ArgEdge     = "arg"     // 通过构造函数的参数注入
+	DependsEdge = "depends" // 通过 DependsOn 设置的间接依赖
+)
+
+// BeanNode 是 bean 依赖图中的一个 bean 。
+type BeanNode struct {
+	ID        string   `json:"id"`
+	Type      string   `json:"type"`
+	Scope     string   `json:"scope"`
+	FileLine  string   `json:"fileLine"`
+	Condition string   `json:"condition,omitempty"` // 条件的判断结果，matched 或者 unmatched
+	Deleted   bool     `json:"deleted,omitempty"`
+	Exports   []string `json:"exports,omitempty"`
+}
+
+// BeanEdge 是 bean 依赖图中的一条依赖关系，表示 From 注入了 To 。
+type BeanEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Kind  string `json:"kind"`
+	Field string `json:"field,omitempty"` // 注入字段的路径，只有 FieldEdge 有值
+}
+
+// BeanGraph 是容器刷新后的 bean 依赖图，包含所有注册的 bean 以及注入过程中产生
+// 的依赖关系，可以输出为 JSON 格式或者 Graphviz 的 DOT 格式。
+type BeanGraph struct {
+	Beans []BeanNode `json:"beans"`
+	Edges []BeanEdge `json:"edges"`
+}
+
+// JSON 返回 JSON 格式的 bean 依赖图。
+func (g *BeanGraph) JSON() ([]byte, error) {
+	return json.MarshalIndent(g, "", "  ")
+}
+
+// DOT 返回 Graphviz DOT 格式的 bean 依赖图，被删除的 bean 以虚线表示。
+func (g *BeanGraph) DOT() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph beans {\n")
+	buf.WriteString("  node [shape=box];\n")
+	for _, n := range g.Beans {
+		label := n.ID + "\n" + n.Type + "\n" + n.FileLine
+		if n.Condition != "" {
+			label += "\ncondition: " + n.Condition
+		}
+		style := ""
+		if n.Deleted {
+			style = ", style=dashed"
+		}
+		fmt.Fprintf(&buf, "  %q [label=%q%s];\n", n.ID, label, style)
+	}
+	for _, e := range g.Edges {
+		label := e.Kind
+		if e.Field != "" {
+			label = e.Field
+		}
+		fmt.Fprintf(&buf, "  %q -> %q [label=%q];\n", e.From, e.To, label)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// Graph 返回容器刷新后的 bean 依赖图，容器刷新成功之前返回 nil 。
+func (c *container) Graph() *BeanGraph {
+	return c.graph
+}
+
+// addEdge 记录注入路径上的当前 bean 对 b 的依赖，field 为空时表示通过构造函数
+// 的参数注入，只记录容器刷新过程中的依赖。
+func (c *container) addEdge(stack *wiringStack, b *BeanDefinition, kind string, field string) {
+	if c.state != Refreshing || len(stack.beans) == 0 {
+		return
+	}
+	if kind == "" {
+		kind = ArgEdge
+		if field != "" {
+			kind = FieldEdge
+		}
+	}
+	c.edges = append(c.edges, BeanEdge{
+		From:  stack.beans[len(stack.beans)-1].ID(),
+		To:    b.ID(),
+		Kind:  kind,
+		Field: field,
+	})
+}
+
+// buildGraph 根据注册的 bean 以及注入过程中记录的依赖关系生成 bean 依赖图。
+func (c *container) buildGraph() *BeanGraph {
+
+	g := &BeanGraph{}
+	ids := make(map[string]bool)
+	for _, b := range c.beans {
+		n := BeanNode{
+			ID:       b.ID(),
+			Type:     b.Type().String(),
+			Scope:    b.ScopeName(),
+			FileLine: b.FileLine(),
+			Deleted:  b.status == Deleted,
+		}
+		if o := b.outcome; o != nil && o.Result != nil {
+			n.Condition = "unmatched"
+			if o.Matched {
+				n.Condition = "matched"
+			}
+		}
+		for _, t := range b.exports {
+			n.Exports = append(n.Exports, t.String())
+		}
+		ids[n.ID] = true
+		g.Beans = append(g.Beans, n)
+	}
+	sort.Slice(g.Beans, func(i, j int) bool {
+		return g.Beans[i].ID < g.Beans[j].ID
+	})
+
+	// 非 singleton 作用域的 bean 每次创建都会产生依赖关系，需要排重。
+	edges := make(map[BeanEdge]bool)
+	for _, e := range c.edges {
+		if edges[e] || !ids[e.From] || !ids[e.To] {
+			continue
+		}
+		edges[e] = true
+		g.Edges = append(g.Edges, e)
+	}
+	return g
+}
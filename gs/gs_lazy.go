@@ -0,0 +1,129 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// lazyHandler 是 lazy bean 的代理对象的 InvocationHandler，第一次调用时才创建
+// bean，之后的调用都转发给创建好的 bean 。
+type lazyHandler struct {
+	c      *container
+	b      *BeanDefinition
+	t      reflect.Type
+	mutex  sync.Mutex
+	target InvocationHandler
+}
+
+// Invoke 创建 bean 失败时通过方法的 error 返回值返回错误，方法没有 error 返回
+// 值时才会 panic 。
+func (h *lazyHandler) Invoke(method string, args ...interface{}) []interface{} {
+	target, err := h.getTarget()
+	if err != nil {
+		return errResults(h.t, method, err)
+	}
+	return target.Invoke(method, args...)
+}
+
+// getTarget 创建 bean 并返回用于调用 bean 的方法的 InvocationHandler 。
+func (h *lazyHandler) getTarget() (InvocationHandler, error) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.target != nil {
+		return h.target, nil
+	}
+
+	stack := newWiringStack(h.c.logger)
+	defer func() {
+		if len(stack.beans) > 0 {
+			h.c.logger.Infof("wiring path %s", stack.path())
+		}
+	}()
+
+	err := h.c.runtimeWire(stack, func() error {
+		if err := h.c.wireBean(h.b, stack); err != nil {
+			return err
+		}
+		h.c.saveLazyDestroyers(stack)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.target = &invocationHandler{
+		target: reflect.ValueOf(h.b.Interface()),
+		t:      h.t,
+		fns:    h.c.intercepted[h.b],
+	}
+	return h.target, nil
+}
+
+// getLazyProxy 返回容器刷新过程中注入到接口 t 的 lazy bean 的代理对象，t 不是
+// 接口或者没有通过 RegisterProxy 注册代理工厂时返回 false，这时 bean 在注入时
+// 创建。
+func (c *container) getLazyProxy(b *BeanDefinition, t reflect.Type) (reflect.Value, bool, error) {
+
+	if !b.lazy || !b.isSingleton() || b.status >= Creating || c.state != Refreshing {
+		return reflect.Value{}, false, nil
+	}
+
+	if t.Kind() != reflect.Interface {
+		return reflect.Value{}, false, nil
+	}
+
+	factory, ok := getProxyFactory(t)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+
+	key := proxyKey{b: b, t: t}
+	if p, ok := c.proxies[key]; ok {
+		return p, true, nil
+	}
+
+	p := reflect.ValueOf(factory(&lazyHandler{c: c, b: b, t: t}))
+	if !p.IsValid() || !p.Type().Implements(t) {
+		return reflect.Value{}, false, fmt.Errorf("proxy of %s doesn't implement it", t)
+	}
+
+	// 代理对象在运行时创建 bean，因此需要保留注入过程中使用的数据。
+	c.scopedProxy = true
+	c.proxies[key] = p
+	return p, true, nil
+}
+
+// saveLazyDestroyers 保存运行时创建的 lazy bean 的销毁函数，它们可能依赖容器
+// 刷新时创建的 bean，因此在其他 bean 之前销毁。调用者需要持有容器的锁。
+func (c *container) saveLazyDestroyers(stack *wiringStack) {
+	if c.state != Refreshed {
+		return
+	}
+	for id, d := range stack.destroyerMap {
+		if !d.current.lazy {
+			delete(stack.destroyerMap, id)
+		}
+	}
+	if len(stack.destroyerMap) > 0 {
+		c.destroyers = append(stack.sortDestroyers(), c.destroyers...)
+	}
+}
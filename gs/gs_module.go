@@ -0,0 +1,255 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/gs/arg"
+	"github.com/go-spring/spring-core/gs/cond"
+)
+
+// SpringAutoconfigureExclude 排除的自动配置模块的名称，多个名称用逗号分隔。
+const SpringAutoconfigureExclude = "spring.autoconfigure.exclude"
+
+// ModuleRegistry 用于在自动配置模块中注册 bean 。
+type ModuleRegistry interface {
+	Object(i interface{}) *BeanDefinition
+	Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition
+}
+
+// Module 自动配置模块，是一组带有共同条件的 bean 注册，例如 starter 提供的默认
+// bean 。容器在判断完用户注册的 bean 的有效性之后才按照 After 和 Before 确定的顺
+// 序逐个处理模块，因此模块中的 OnMissingBean 等条件能够看到用户注册的 bean 以及
+// 之前的模块注册的 bean，而用户注册的 bean 的条件看不到模块注册的 bean 。
+type Module struct {
+	name     string
+	fn       func(r ModuleRegistry)
+	cond     cond.Condition
+	after    []string
+	before   []string
+	fileLine string
+}
+
+// NewModule 创建名为 name 的自动配置模块，fn 在容器刷新时执行，每个容器执行一次。
+func NewModule(name string, fn func(r ModuleRegistry)) *Module {
+	_, file, line, _ := runtime.Caller(1)
+	return &Module{name: name, fn: fn, fileLine: fmt.Sprintf("%s:%d", file, line)}
+}
+
+// Name 返回模块的名称。
+func (m *Module) Name() string {
+	return m.name
+}
+
+// On 设置模块的条件，条件不满足时模块中的 bean 都不会注册。
+func (m *Module) On(c cond.Condition) *Module {
+	m.cond = c
+	return m
+}
+
+// After 设置模块在名为 names 的模块之后处理，不存在的模块被忽略。
+func (m *Module) After(names ...string) *Module {
+	m.after = append(m.after, names...)
+	return m
+}
+
+// Before 设置模块在名为 names 的模块之前处理，不存在的模块被忽略。
+func (m *Module) Before(names ...string) *Module {
+	m.before = append(m.before, names...)
+	return m
+}
+
+var modules []*Module
+
+// RegisterModule 注册全局的自动配置模块，通常在 init 函数中调用，App 启动时自动
+// 使用所有的全局模块。
+func RegisterModule(m *Module) {
+	modules = append(modules, m)
+}
+
+// Module 为容器添加自动配置模块。需要注意的是该方法在注入开始后就不能再调用了。
+func (c *container) Module(m *Module) {
+	c.modules = append(c.modules, m)
+}
+
+// moduleRegistry 收集模块注册的 bean 。
+type moduleRegistry struct {
+	beans []*BeanDefinition
+}
+
+func (r *moduleRegistry) Object(i interface{}) *BeanDefinition {
+	b := NewBean(reflect.ValueOf(i))
+	r.beans = append(r.beans, b)
+	return b
+}
+
+func (r *moduleRegistry) Provide(ctor interface{}, args ...arg.Arg) *BeanDefinition {
+	b := NewBean(ctor, args...)
+	r.beans = append(r.beans, b)
+	return b
+}
+
+// sortModules 按照 After 和 Before 对模块进行拓扑排序，没有顺序要求的模块保持添
+// 加的顺序。
+func sortModules(modules []*Module) ([]*Module, error) {
+
+	index := make(map[string]int)
+	for i, m := range modules {
+		if _, ok := index[m.name]; ok {
+			return nil, fmt.Errorf("found duplicate modules %q", m.name)
+		}
+		index[m.name] = i
+	}
+
+	// deps[i] 是必须在 modules[i] 之前处理的模块。
+	deps := make([][]int, len(modules))
+	for i, m := range modules {
+		for _, name := range m.after {
+			if j, ok := index[name]; ok {
+				deps[i] = append(deps[i], j)
+			}
+		}
+		for _, name := range m.before {
+			if j, ok := index[name]; ok {
+				deps[j] = append(deps[j], i)
+			}
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+
+	var (
+		sorted []*Module
+		state  = make([]int, len(modules))
+		path   []string
+		visit  func(i int) error
+	)
+
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("found module cycle %s -> %s", strings.Join(path, " -> "), modules[i].name)
+		}
+		state[i] = visiting
+		path = append(path, modules[i].name)
+		for _, j := range deps[i] {
+			if err := visit(j); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		sorted = append(sorted, modules[i])
+		return nil
+	}
+
+	for i := range modules {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// resolveModules 在用户注册的 bean 判断完有效性之后依次处理自动配置模块，被排除
+// 或者条件不满足的模块记录在条件评估报告中。
+func (c *container) resolveModules() error {
+
+	if len(c.modules) == 0 {
+		return nil
+	}
+
+	// 全局模块和显式添加的模块可能是同一个对象。
+	var list []*Module
+	added := make(map[*Module]bool)
+	for _, m := range c.modules {
+		if !added[m] {
+			added[m] = true
+			list = append(list, m)
+		}
+	}
+
+	sorted, err := sortModules(list)
+	if err != nil {
+		return err
+	}
+
+	var exclude []string
+	err = c.p.Bind(&exclude, conf.Tag("${"+SpringAutoconfigureExclude+":=}"))
+	if err != nil {
+		return err
+	}
+	excluded := make(map[string]bool)
+	for _, name := range exclude {
+		excluded[strings.TrimSpace(name)] = true
+	}
+
+	for _, m := range sorted {
+
+		outcome := &ConditionOutcome{ID: "module:" + m.name, FileLine: m.fileLine}
+		c.moduleOutcomes = append(c.moduleOutcomes, outcome)
+
+		if excluded[m.name] {
+			outcome.Message = "excluded by " + SpringAutoconfigureExclude
+			c.logger.Infof("module %s excluded", m.name)
+			continue
+		}
+
+		if m.cond != nil {
+			r, err := cond.Evaluate(m.cond, c)
+			if err != nil {
+				return fmt.Errorf("module %s condition error: %w", m.name, err)
+			}
+			outcome.Result = r
+			if !r.Matched {
+				continue
+			}
+		}
+		outcome.Matched = true
+
+		r := &moduleRegistry{}
+		m.fn(r)
+		start := len(c.beans)
+		for _, b := range r.beans {
+			c.beans = append(c.beans, b)
+			c.registerBean(b)
+		}
+		if err = c.registerProducts(r.beans); err != nil {
+			return err
+		}
+		if err = c.replaceBeans(); err != nil {
+			return err
+		}
+		for _, b := range c.beans[start:] {
+			if err = c.resolveBean(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
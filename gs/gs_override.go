@@ -0,0 +1,109 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"strconv"
+)
+
+// SpringAllowBeanOverriding 是否允许 id 相同的 bean 相互覆盖，允许时后注册的 bean
+// 覆盖先注册的 bean，否则容器刷新时返回 found duplicate beans 错误。
+const SpringAllowBeanOverriding = "spring.main.allow-bean-definition-overriding"
+
+// replaceBeans 处理通过 Replaces 显式设置的替换关系，被替换的 bean 标记为已删
+// 除，并且在条件评估报告中记录替换它的 bean 。替换在其他 bean 的条件评估之前进
+// 行，因此 OnBean、OnMissingBean 等条件看到的是替换之后的结果。只有条件满足的
+// bean 才能替换其他 bean，只处理当前容器中的 bean，不会影响父容器。
+func (c *container) replaceBeans() error {
+
+	var replacers []*BeanDefinition
+	for _, b := range c.beans {
+		if len(b.replaces) == 0 {
+			continue
+		}
+		if err := c.resolveBean(b); err != nil {
+			return err
+		}
+		replacers = append(replacers, b)
+	}
+
+	for _, b := range replacers {
+		if b.status == Deleted {
+			continue
+		}
+		for _, selector := range b.replaces {
+			match, err := beanMatcher(selector)
+			if err != nil {
+				return err
+			}
+			for _, r := range c.beans {
+				if r != b && r.status != Deleted && match(r) {
+					c.replaceBean(r, b, "replaced")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// overrideBeans 在允许覆盖时由后注册的 bean 覆盖 id 相同的先注册的 bean 。
+func (c *container) overrideBeans() {
+
+	if ok, _ := strconv.ParseBool(c.p.Get(SpringAllowBeanOverriding)); !ok {
+		return
+	}
+
+	beansById := make(map[string]*BeanDefinition)
+	for _, b := range c.beans {
+		if b.status == Deleted {
+			continue
+		}
+		if d, ok := beansById[b.ID()]; ok {
+			c.replaceBean(d, b, "overridden")
+		}
+		beansById[b.ID()] = b
+	}
+}
+
+// replaceBean 使用 b 替换 r，r 的产品 bean 以及以 r 为 parent 的 method bean 也
+// 一起被删除。
+func (c *container) replaceBean(r, b *BeanDefinition, action string) {
+	r.status = Deleted
+	if r.outcome == nil {
+		r.outcome = newConditionOutcome(r)
+	}
+	r.outcome.Matched = false
+	r.outcome.Message = action + " by " + b.String()
+	c.logger.Infof("%s %s by %s", r, action, b)
+	for _, p := range c.beans {
+		if p.status == Deleted {
+			continue
+		}
+		if p.factory == r || isParentBean(p, r) {
+			c.replaceBean(p, b, action)
+		}
+	}
+}
+
+// isParentBean 返回 r 是否匹配 method bean p 的 parent bean 选择器。
+func isParentBean(p, r *BeanDefinition) bool {
+	if !p.method {
+		return false
+	}
+	match, err := beanMatcher(parentSelector(p))
+	return err == nil && match(r)
+}
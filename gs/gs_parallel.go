@@ -0,0 +1,385 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/go-spring/spring-core/gs/arg"
+)
+
+// SpringRefreshParallel 是否并行创建和初始化 bean 。
+const SpringRefreshParallel = "spring.refresh.parallel"
+
+// parallelWiring 保存并行注入时各个 goroutine 共享的状态。容器的数据由 mutex
+// 保护，只有在执行构造函数、初始化函数等用户代码时才会释放。容器刷新之后在运行时
+// 注入 bean 也使用相同的模型，例如创建 lazy bean 或者非 singleton 作用域的 bean 。
+type parallelWiring struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	running int // 正在注入的任务数
+	waiting int // 等待其他任务的任务数
+	failed  map[*BeanDefinition]error
+	panics  map[int]interface{}
+}
+
+func newParallelWiring() *parallelWiring {
+	p := &parallelWiring{
+		failed: make(map[*BeanDefinition]error),
+		panics: make(map[int]interface{}),
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// wiring 返回当前使用的锁，并行注入时返回 parallel，容器刷新之后返回 runtime，
+// 串行注入时返回 nil 。
+func (c *container) wiring() *parallelWiring {
+	if c.parallel != nil {
+		return c.parallel
+	}
+	return c.runtime
+}
+
+// lock 并行注入或者运行时注入时获取容器的锁，返回值用于释放锁。运行时每个持有锁
+// 的调用者都是一个正在注入的任务。
+func (c *container) lock() func() {
+	if p := c.parallel; p != nil {
+		p.mutex.Lock()
+		return p.mutex.Unlock
+	}
+	if p := c.runtime; p != nil {
+		p.mutex.Lock()
+		p.running++
+		return func() {
+			p.running--
+			p.cond.Broadcast()
+			p.mutex.Unlock()
+		}
+	}
+	return func() {}
+}
+
+// withoutLock 并行注入或者运行时注入时释放容器的锁然后执行用户代码 fn，fn 返回
+// 或者 panic 之后重新获取锁。
+func (c *container) withoutLock(fn func()) {
+	if p := c.wiring(); p != nil {
+		p.mutex.Unlock()
+		defer p.mutex.Lock()
+	}
+	fn()
+}
+
+// runtimeWire 持有容器的锁执行注入任务 fn，注入失败时记录注入路径上的 bean，
+// 等待这些 bean 的任务返回相同的错误，而不是得到没有完成注入的 bean 。
+func (c *container) runtimeWire(stack *wiringStack, fn func() error) error {
+	defer c.lock()()
+	err := fn()
+	if p := c.wiring(); p != nil && err != nil {
+		for _, b := range stack.beans {
+			p.failed[b] = err
+		}
+	}
+	return err
+}
+
+// waitBean 并行注入或者运行时注入时如果 bean 正在由其他任务注入，则等待其注入
+// 完成。
+func (c *container) waitBean(b *BeanDefinition, stack *wiringStack) error {
+
+	p := c.wiring()
+	if p == nil {
+		return nil
+	}
+
+	// bean 在当前的注入路径上，属于循环依赖，按照串行注入的方式处理。
+	for _, x := range stack.beans {
+		if x == b {
+			return nil
+		}
+	}
+
+	for b.status == Creating || b.status == Created {
+		if err, ok := p.failed[b]; ok {
+			return err
+		}
+		// 所有的任务都在等待说明存在静态分析没有发现的循环依赖。
+		if p.waiting+1 >= p.running {
+			return errors.New("found circle autowire")
+		}
+		p.waiting++
+		p.cond.Wait()
+		p.waiting--
+	}
+	return nil
+}
+
+// beansByReceiver 返回可以注入到 t 类型的接收者的所有 bean 。
+func (c *container) beansByReceiver(t reflect.Type) []*BeanDefinition {
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		t = t.Elem()
+		if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+			return c.beans
+		}
+	}
+	return c.beansByType[t]
+}
+
+// fieldDepends 返回 t 类型的结构体通过 autowire 标签可能注入的所有 bean 。
+func (c *container) fieldDepends(t reflect.Type) []*BeanDefinition {
+
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var result []*BeanDefinition
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		tag, ok := ft.Tag.Lookup("autowire")
+		if !ok {
+			tag, ok = ft.Tag.Lookup("inject")
+		}
+		if ok {
+			if !strings.HasSuffix(tag, ",lazy") {
+				result = append(result, c.beansByReceiver(ft.Type)...)
+			}
+			continue
+		}
+		if ft.Anonymous && ft.Type.Kind() == reflect.Struct {
+			result = append(result, c.fieldDepends(ft.Type)...)
+		}
+	}
+	return result
+}
+
+// beanDepends 根据间接依赖项、构造函数的参数以及字段的 autowire 标签分析 bean
+// 可能依赖的其他 bean，分析结果只用来确定并行注入的顺序。非 singleton 作用域的
+// bean 在注入时才会创建，因此它们的依赖也算作 bean 的依赖。
+func (c *container) beanDepends(b *BeanDefinition) ([]*BeanDefinition, error) {
+
+	var result []*BeanDefinition
+	visited := map[*BeanDefinition]bool{b: true}
+
+	var collect func(b *BeanDefinition) error
+	collect = func(b *BeanDefinition) error {
+
+		var beans []*BeanDefinition
+		for _, s := range b.depends {
+			r, err := c.findBean(s)
+			if err != nil {
+				return err
+			}
+			beans = append(beans, r...)
+		}
+
+		if b.f != nil {
+			for i := 0; ; i++ {
+				t, ok := b.f.In(i)
+				if !ok {
+					break
+				}
+				if arg.IsParams(t) {
+					beans = append(beans, c.fieldDepends(t)...)
+					continue
+				}
+				beans = append(beans, c.beansByReceiver(t)...)
+			}
+		}
+
+		beans = append(beans, c.fieldDepends(b.Type())...)
+
+		for _, d := range beans {
+			if d.status == Deleted || visited[d] {
+				continue
+			}
+			visited[d] = true
+			result = append(result, d)
+			if !d.isSingleton() {
+				if err := collect(d); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := collect(b); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// wireParallel 按照 bean 之间的依赖关系并行注入，没有依赖关系的 bean 在不同的
+// goroutine 中创建和初始化。依赖关系存在环时退化为串行注入。beans 是按照 ID 排
+// 好序的，出错时返回排在最前面的 bean 的错误，保证错误信息是确定的。
+func (c *container) wireParallel(beans []*BeanDefinition, stack *wiringStack) error {
+
+	index := make(map[*BeanDefinition]int)
+	for i, b := range beans {
+		index[b] = i
+	}
+
+	count := make([]int, len(beans))
+	dependents := make([][]int, len(beans))
+	for i, b := range beans {
+		deps, err := c.beanDepends(b)
+		if err != nil {
+			return err
+		}
+		for _, d := range deps {
+			if j, ok := index[d]; ok && d.status != Wired {
+				dependents[j] = append(dependents[j], i)
+				count[i]++
+			}
+		}
+	}
+
+	if hasDependCycle(count, dependents) {
+		c.logger.Info("found dependency cycle, wire beans sequentially")
+		for _, b := range beans {
+			if err := c.wireBean(b, stack); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	p := newParallelWiring()
+	c.parallel = p
+	defer func() { c.parallel = nil }()
+
+	var wg sync.WaitGroup
+	stacks := make([]*wiringStack, len(beans))
+	errs := make([]error, len(beans))
+
+	var start func(i int)
+	start = func(i int) {
+		p.running++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.mutex.Lock()
+			defer p.mutex.Unlock()
+			s := newWiringStack(c.logger)
+			s.step = stack.step
+			err := func() (err error) {
+				// 用户代码的 panic 在所有任务结束之后由调用者重新抛出。
+				defer func() {
+					if r := recover(); r != nil {
+						p.panics[i] = r
+						err = fmt.Errorf("panic: %v", r)
+					}
+				}()
+				return c.wireBean(beans[i], s)
+			}()
+			p.running--
+			stacks[i], errs[i] = s, err
+			if err != nil {
+				for _, b := range s.beans {
+					p.failed[b] = err
+				}
+			} else {
+				for _, j := range dependents[i] {
+					if count[j]--; count[j] == 0 {
+						start(j)
+					}
+				}
+			}
+			p.cond.Broadcast()
+		}()
+	}
+
+	p.mutex.Lock()
+	for i := range beans {
+		if count[i] == 0 {
+			start(i)
+		}
+	}
+	p.mutex.Unlock()
+	wg.Wait()
+
+	for i := range beans {
+		if r, ok := p.panics[i]; ok {
+			panic(r)
+		}
+	}
+
+	for i, err := range errs {
+		if err != nil {
+			stack.beans = stacks[i].beans
+			return skippedError(err, beans, stacks)
+		}
+	}
+
+	// 合并各个任务记录的销毁函数顺序以及延迟注入的字段。
+	for _, s := range stacks {
+		for _, d := range s.destroyerMap {
+			x := stack.saveDestroyer(d.current)
+			for _, b := range d.earlier {
+				x.after(b)
+			}
+		}
+		stack.lazyFields = append(stack.lazyFields, s.lazyFields...)
+	}
+	return nil
+}
+
+// skippedError 在 err 中报告因为依赖的 bean 注入失败而没有开始注入的 bean 。
+func skippedError(err error, beans []*BeanDefinition, stacks []*wiringStack) error {
+	var skipped []string
+	for i, b := range beans {
+		if stacks[i] == nil && b.status != Wired {
+			skipped = append(skipped, b.ID())
+		}
+	}
+	if len(skipped) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w, skipped dependent beans [%s]", err, strings.Join(skipped, ", "))
+}
+
+// hasDependCycle 返回依赖关系中是否存在环，count 是每个 bean 依赖的 bean 的数量。
+func hasDependCycle(count []int, dependents [][]int) bool {
+	n := make([]int, len(count))
+	copy(n, count)
+	var queue []int
+	for i := range n {
+		if n[i] == 0 {
+			queue = append(queue, i)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		i := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, j := range dependents[i] {
+			if n[j]--; n[j] == 0 {
+				queue = append(queue, j)
+			}
+		}
+	}
+	return visited < len(count)
+}
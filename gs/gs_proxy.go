@@ -0,0 +1,291 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-spring/spring-base/util"
+)
+
+// Invocation 描述一次通过代理对象进行的方法调用。
+type Invocation interface {
+
+	// Target 返回被代理的 bean 。
+	Target() interface{}
+
+	// Method 返回调用的方法名。
+	Method() string
+
+	// Args 返回调用的参数，可变参数以切片的形式作为最后一个参数。
+	Args() []interface{}
+
+	// Proceed 调用下一个拦截器，如果没有下一个拦截器则调用 bean 的方法。方法的
+	// 最后一个返回值如果是 error 类型则作为 Proceed 的 error 返回，其余返回值
+	// 按顺序保存在返回的切片中。
+	Proceed() ([]interface{}, error)
+}
+
+// Interceptor 方法拦截器，可以在 Proceed 的前后添加日志、重试、计时、鉴权等逻辑。
+type Interceptor func(inv Invocation) ([]interface{}, error)
+
+// InvocationHandler 代理对象通过 Invoke 方法将调用转发给拦截器和 bean 。
+type InvocationHandler interface {
+
+	// Invoke 调用名为 method 的方法，返回值与方法的返回值一一对应。如果方法没
+	// 有 error 类型的返回值而拦截器返回了 error，那么 Invoke 会 panic 。
+	Invoke(method string, args ...interface{}) []interface{}
+}
+
+// ProxyFactory 为接口创建代理对象，代理对象的每个方法都应该调用 h.Invoke 。
+type ProxyFactory func(h InvocationHandler) interface{}
+
+var (
+	proxyMutex     sync.RWMutex
+	proxyFactories = map[reflect.Type]ProxyFactory{}
+)
+
+// RegisterProxy 注册接口的代理工厂，i 是 (*Service)(nil) 形式的接口类型。Go
+// 无法在运行时生成接口的实现，因此需要为被拦截的接口提供代理对象的实现，例如：
+//
+//	type serviceProxy struct{ h gs.InvocationHandler }
+//
+//	func (p *serviceProxy) Hello(name string) (string, error) {
+//		out := p.h.Invoke("Hello", name)
+//		err, _ := out[1].(error)
+//		return out[0].(string), err
+//	}
+func RegisterProxy(i interface{}, factory ProxyFactory) {
+	t := util.Indirect(reflect.TypeOf(i))
+	if t.Kind() != reflect.Interface {
+		panic(errors.New("only interface type can be proxied"))
+	}
+	proxyMutex.Lock()
+	defer proxyMutex.Unlock()
+	proxyFactories[t] = factory
+}
+
+// getProxyFactory 返回接口 t 的代理工厂。
+func getProxyFactory(t reflect.Type) (ProxyFactory, bool) {
+	proxyMutex.RLock()
+	defer proxyMutex.RUnlock()
+	factory, ok := proxyFactories[t]
+	return factory, ok
+}
+
+// checkIntercepted 检查被拦截的 bean 导出的接口都注册了代理工厂，否则拦截器不
+// 会生效，因此在容器刷新时返回错误。
+func checkIntercepted(b *BeanDefinition) error {
+	if len(b.exports) == 0 {
+		return fmt.Errorf("intercepted bean should export interfaces, %s", b)
+	}
+	for _, t := range b.exports {
+		if _, ok := getProxyFactory(t); !ok {
+			return fmt.Errorf("no proxy registered for %s, %s", t, b)
+		}
+	}
+	return nil
+}
+
+// interceptor 保存拦截器及其作用的 bean 选择器。
+type interceptor struct {
+	selector util.BeanSelector
+	fn       Interceptor
+}
+
+// invocationHandler 按照顺序执行拦截器，最后调用 bean 的方法。
+type invocationHandler struct {
+	target reflect.Value
+	t      reflect.Type
+	fns    []Interceptor
+}
+
+func (h *invocationHandler) Invoke(method string, args ...interface{}) []interface{} {
+
+	m, ok := h.t.MethodByName(method)
+	if !ok {
+		panic(fmt.Errorf("method %s not found in %s", method, h.t))
+	}
+
+	inv := &invocation{h: h, method: method, mt: m.Type, args: args}
+	results, err := inv.Proceed()
+
+	mt := m.Type
+	n := mt.NumOut()
+	withErr := n > 0 && util.IsErrorType(mt.Out(n-1))
+	if withErr {
+		n--
+	} else if err != nil {
+		panic(err)
+	}
+
+	// 拦截器返回 nil 表示方法的返回值都是零值，例如异步执行的方法。
+	if results == nil {
+		results = make([]interface{}, n)
+	}
+
+	if len(results) != n {
+		panic(fmt.Errorf("method %s should return %d results but got %d", method, n, len(results)))
+	}
+
+	ret := make([]interface{}, 0, mt.NumOut())
+	for i, r := range results {
+		if r == nil {
+			r = reflect.Zero(mt.Out(i)).Interface()
+		}
+		ret = append(ret, r)
+	}
+	if withErr {
+		ret = append(ret, err)
+	}
+	return ret
+}
+
+// errResults 返回接口 t 的方法的零值结果以及错误，方法没有 error 返回值时 panic 。
+func errResults(t reflect.Type, method string, err error) []interface{} {
+	m, ok := t.MethodByName(method)
+	if !ok {
+		panic(fmt.Errorf("method %s not found in %s", method, t))
+	}
+	mt := m.Type
+	n := mt.NumOut()
+	if n == 0 || !util.IsErrorType(mt.Out(n-1)) {
+		panic(err)
+	}
+	ret := make([]interface{}, 0, n)
+	for i := 0; i < n-1; i++ {
+		ret = append(ret, reflect.Zero(mt.Out(i)).Interface())
+	}
+	return append(ret, err)
+}
+
+// invocation 是 Invocation 的默认实现，index 表示下一个要执行的拦截器。
+type invocation struct {
+	h      *invocationHandler
+	method string
+	mt     reflect.Type
+	args   []interface{}
+	index  int
+}
+
+func (inv *invocation) Target() interface{} {
+	return inv.h.target.Interface()
+}
+
+func (inv *invocation) Method() string {
+	return inv.method
+}
+
+func (inv *invocation) Args() []interface{} {
+	return inv.args
+}
+
+func (inv *invocation) Proceed() ([]interface{}, error) {
+
+	if inv.index < len(inv.h.fns) {
+		next := *inv
+		next.index++
+		return inv.h.fns[inv.index](&next)
+	}
+
+	if len(inv.args) != inv.mt.NumIn() {
+		return nil, fmt.Errorf("method %s should have %d args but got %d", inv.method, inv.mt.NumIn(), len(inv.args))
+	}
+
+	in := make([]reflect.Value, len(inv.args))
+	for i, arg := range inv.args {
+		if arg == nil {
+			in[i] = reflect.Zero(inv.mt.In(i))
+		} else {
+			in[i] = reflect.ValueOf(arg)
+		}
+	}
+
+	var out []reflect.Value
+	fn := inv.h.target.MethodByName(inv.method)
+	if inv.mt.IsVariadic() {
+		out = fn.CallSlice(in)
+	} else {
+		out = fn.Call(in)
+	}
+
+	var err error
+	if n := len(out); n > 0 && util.IsErrorType(out[n-1].Type()) {
+		if e := out[n-1].Interface(); e != nil {
+			err = e.(error)
+		}
+		out = out[:n-1]
+	}
+
+	results := make([]interface{}, len(out))
+	for i, o := range out {
+		results[i] = o.Interface()
+	}
+	return results, err
+}
+
+type proxyKey struct {
+	b *BeanDefinition
+	t reflect.Type
+}
+
+// getProxy 如果有拦截器作用于 bean，并且 t 是 bean 导出的接口，那么返回 bean
+// 在接口 t 上的代理对象，否则返回 bean 本身。singleton bean 的代理对象只创建一次。
+func (c *container) getProxy(b *BeanDefinition, t reflect.Type, v reflect.Value) (reflect.Value, error) {
+
+	fns := c.intercepted[b]
+	if len(fns) == 0 || t.Kind() != reflect.Interface {
+		return v, nil
+	}
+
+	exported := false
+	for _, typ := range b.exports {
+		if typ == t {
+			exported = true
+			break
+		}
+	}
+	if !exported {
+		return v, nil
+	}
+
+	key := proxyKey{b: b, t: t}
+	if b.isSingleton() {
+		if p, ok := c.proxies[key]; ok {
+			return p, nil
+		}
+	}
+
+	factory, ok := getProxyFactory(t)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("no proxy registered for %s, %s", t, b)
+	}
+
+	target := reflect.ValueOf(v.Interface())
+	p := reflect.ValueOf(factory(&invocationHandler{target: target, t: t, fns: fns}))
+	if !p.IsValid() || !p.Type().Implements(t) {
+		return reflect.Value{}, fmt.Errorf("proxy of %s doesn't implement it", t)
+	}
+
+	if b.isSingleton() {
+		c.proxies[key] = p
+	}
+	return p, nil
+}
@@ -0,0 +1,254 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-spring/spring-core/conf"
+	"github.com/go-spring/spring-core/dync"
+)
+
+// refreshBean 保存 refresh 作用域的 bean 的当前实例。
+type refreshBean struct {
+	instance interface{}
+	factory  ObjectFactory
+	stale    bool // 绑定的属性发生了变化，需要重新创建
+}
+
+// refreshScope 在容器内只保留一个实例，当实例绑定的属性通过 dync.Properties 的
+// Refresh 或者 Update 方法发生变化时将该实例标记为过期，下次获取时重新创建，新
+// 的实例创建成功后才销毁过期的实例，创建失败时可以继续使用过期的实例。
+type refreshScope struct {
+	p       *dync.Properties
+	mutex   sync.Mutex
+	beans   map[string]*refreshBean
+	watched map[string]bool // 已经监听的 bean 和属性
+}
+
+func newRefreshScope(p *dync.Properties) *refreshScope {
+	return &refreshScope{
+		p:       p,
+		beans:   make(map[string]*refreshBean),
+		watched: make(map[string]bool),
+	}
+}
+
+// boundFactory 是可以返回 bean 实例绑定的属性的 ObjectFactory 。
+type boundFactory interface {
+	boundKeys() []string
+}
+
+func (s *refreshScope) Get(ctx context.Context, beanID string, factory ObjectFactory) (interface{}, error) {
+
+	s.mutex.Lock()
+	r, ok := s.beans[beanID]
+	s.mutex.Unlock()
+	if ok && !r.stale {
+		return r.instance, nil
+	}
+
+	// 创建实例的时候不能持有锁，因为实例可能依赖其他 refresh 作用域的 bean 。
+	i, err := factory.Create()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if prev, ok := s.beans[beanID]; ok {
+		if !prev.stale {
+			factory.Destroy(i)
+			return prev.instance, nil
+		}
+		prev.factory.Destroy(prev.instance)
+	}
+	s.beans[beanID] = &refreshBean{instance: i, factory: factory}
+
+	if f, ok := factory.(boundFactory); ok {
+		for _, key := range f.boundKeys() {
+			if err = s.watch(beanID, key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return i, nil
+}
+
+// watch 监听 bean 绑定的属性，每个 bean 的每个属性只监听一次。
+func (s *refreshScope) watch(beanID string, key string) error {
+	if s.watched[beanID+"|"+key] {
+		return nil
+	}
+	s.watched[beanID+"|"+key] = true
+	w := &refreshWatcher{s: s, beanID: beanID}
+	return s.p.BindValue(reflect.ValueOf(w), conf.BindParam{Key: key})
+}
+
+// invalidate 将 bean 的当前实例标记为过期，下次获取时重新创建。
+func (s *refreshScope) invalidate(beanID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r, ok := s.beans[beanID]; ok {
+		r.stale = true
+	}
+}
+
+// staleInstance 返回 bean 过期的实例，重新创建失败时使用。
+func (s *refreshScope) staleInstance(beanID string) (interface{}, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r, ok := s.beans[beanID]; ok && r.stale {
+		return r.instance, true
+	}
+	return nil, false
+}
+
+// reset 容器关闭时取出所有的实例，由调用者在释放容器的锁之后销毁。
+func (s *refreshScope) reset() map[string]*refreshBean {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	beans := s.beans
+	s.beans = make(map[string]*refreshBean)
+	return beans
+}
+
+// refreshWatcher 以 dync.Value 的形式监听属性的变化，属性的值确实发生变化时才
+// 销毁 bean 的实例，因为 dync.Properties 刷新时可能通知没有变化的属性。
+type refreshWatcher struct {
+	s      *refreshScope
+	beanID string
+	value  string
+	ready  bool
+}
+
+func (w *refreshWatcher) Validate(prop *conf.Properties, param conf.BindParam) error {
+	return nil
+}
+
+func (w *refreshWatcher) Refresh(prop *conf.Properties, param conf.BindParam) error {
+	value := snapshotKey(prop, param.Key)
+	if w.ready && value != w.value {
+		w.s.invalidate(w.beanID)
+	}
+	w.value = value
+	w.ready = true
+	return nil
+}
+
+// snapshotKey 返回 key 及其所有子属性的值，用于判断属性是否发生了变化。
+func snapshotKey(prop *conf.Properties, key string) string {
+	m := prop.Subset(key)
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf strings.Builder
+	for _, k := range keys {
+		buf.WriteString(k + "=" + m[k] + "\n")
+	}
+	return buf.String()
+}
+
+// bindKey 记录注入路径上的当前 bean 绑定的属性，refresh 作用域的 bean 在这些
+// 属性发生变化时重新创建。
+func (s *wiringStack) bindKey(key string) {
+	if len(s.beans) == 0 || key == "" {
+		return
+	}
+	b := s.beans[len(s.beans)-1]
+	if b.scope != RefreshScope {
+		return
+	}
+	if s.keys == nil {
+		s.keys = make(map[*BeanDefinition][]string)
+	}
+	s.keys[b] = append(s.keys[b], key)
+}
+
+// refreshHandler 是 refresh 作用域的 bean 的代理对象的 InvocationHandler，每
+// 次调用都转发给 bean 的当前实例，因此注入点持有的代理对象始终不变。
+type refreshHandler struct {
+	c   *container
+	b   *BeanDefinition
+	t   reflect.Type
+	fns []Interceptor
+}
+
+// Invoke 重新创建 bean 的实例失败时继续使用过期的实例，没有可用的实例时通过方
+// 法的 error 返回值返回错误，方法没有 error 返回值时才会 panic 。
+func (h *refreshHandler) Invoke(method string, args ...interface{}) []interface{} {
+	var v reflect.Value
+	stack := newWiringStack(h.c.logger)
+	err := h.c.runtimeWire(stack, func() (err error) {
+		v, err = h.c.getScopedBean(h.b, stack)
+		return err
+	})
+	if err != nil {
+		i, ok := h.staleInstance()
+		if !ok {
+			return errResults(h.t, method, err)
+		}
+		h.c.logger.Errorf("refresh %s error, keep the stale instance: %v", h.b, err)
+		v = reflect.ValueOf(i)
+	}
+	target := reflect.ValueOf(v.Interface())
+	return (&invocationHandler{target: target, t: h.t, fns: h.fns}).Invoke(method, args...)
+}
+
+func (h *refreshHandler) staleInstance() (interface{}, bool) {
+	s, ok := h.c.scopes[h.b.scope].(*refreshScope)
+	if !ok {
+		return nil, false
+	}
+	return s.staleInstance(h.b.ID())
+}
+
+// getRefreshProxy 返回 refresh 作用域的 bean 在接口 t 上的代理对象，t 不是接
+// 口或者没有通过 RegisterProxy 注册代理工厂时返回 false 。
+func (c *container) getRefreshProxy(b *BeanDefinition, t reflect.Type) (reflect.Value, bool, error) {
+
+	if t.Kind() != reflect.Interface {
+		return reflect.Value{}, false, nil
+	}
+
+	factory, ok := getProxyFactory(t)
+	if !ok {
+		return reflect.Value{}, false, nil
+	}
+
+	key := proxyKey{b: b, t: t}
+	if p, ok := c.proxies[key]; ok {
+		return p, true, nil
+	}
+
+	p := reflect.ValueOf(factory(&refreshHandler{c: c, b: b, t: t, fns: c.intercepted[b]}))
+	if !p.IsValid() || !p.Type().Implements(t) {
+		return reflect.Value{}, false, fmt.Errorf("proxy of %s doesn't implement it", t)
+	}
+
+	// 代理对象在运行时创建 bean 的实例，因此需要保留注入过程中使用的数据。
+	c.scopedProxy = true
+	c.proxies[key] = p
+	return p, true, nil
+}
@@ -0,0 +1,279 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs/arg"
+)
+
+// OverlapPolicy 定时任务的上次执行还没有结束时如何处理本次执行，不适用于 @delay
+// 形式的任务，因为它们总是在上次执行结束之后才开始计时。
+type OverlapPolicy int
+
+const (
+	OverlapSkip       OverlapPolicy = iota // 跳过本次执行
+	OverlapQueue                           // 等待上次执行结束后再执行
+	OverlapConcurrent                      // 和上次执行同时进行
+)
+
+// Clock 定时任务使用的时钟，默认使用系统时钟。注册导出为 Clock 的 bean 可以替换
+// 默认的时钟，例如在测试中手动推进时间。
+type Clock interface {
+	Now() time.Time
+
+	// NewTimer 返回在 d 之后发出信号的 chan 以及停止计时的函数。
+	NewTimer(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	t := time.NewTimer(d)
+	return t.C, t.Stop
+}
+
+// getClock 返回定时任务使用的时钟。
+func (c *container) getClock() Clock {
+	if c.clock != nil {
+		return c.clock
+	}
+	return systemClock{}
+}
+
+// ScheduledTask 定时任务，容器刷新之后在 Go 创建的 goroutine 中按照执行计划运
+// 行，容器关闭时停止。任务函数的参数和 Invoke 一样通过 arg 包绑定，每次执行时重
+// 新获取，context.Context 类型的参数注入容器的 ctx 。任务函数返回的 error 和发
+// 生的 panic 只记录日志，不影响后续的执行。
+type ScheduledTask struct {
+	name    string
+	spec    string
+	trigger Trigger
+	r       *arg.Callable
+	bean    *BeanDefinition
+	overlap OverlapPolicy
+	mutex   sync.Mutex
+	running int // 正在执行的次数
+	pending int // 等待执行的次数
+}
+
+func newScheduledTask(spec string, fn interface{}, args []arg.Arg, bean *BeanDefinition) *ScheduledTask {
+
+	t := reflect.TypeOf(fn)
+	if !util.IsFuncType(t) || !(util.ReturnNothing(t) || util.ReturnOnlyError(t)) {
+		panic(errors.New("task should be func(...) or func(...)error"))
+	}
+
+	if bean != nil {
+		if t.NumIn() == 0 || !util.HasReceiver(t, bean.Value()) {
+			panic(errors.New("task of bean should be func(bean, ...) or func(bean, ...)error"))
+		}
+		// bean 作为任务函数的第一个参数。
+		if len(args) > 0 {
+			if _, ok := args[0].(arg.IndexArg); ok {
+				args = append([]arg.Arg{arg.R0(bean)}, args...)
+			} else {
+				args = append([]arg.Arg{bean}, args...)
+			}
+		} else {
+			args = []arg.Arg{bean}
+		}
+	}
+
+	r, err := arg.Bind(fn, args, 2)
+	util.Panic(err).When(err != nil)
+
+	_, _, name := util.FileLine(fn)
+	return &ScheduledTask{name: name, spec: spec, r: r, bean: bean}
+}
+
+// Name 设置任务的名称，默认为任务函数的函数名，用于日志以及报告阻塞关闭的
+// goroutine 。
+func (t *ScheduledTask) Name(name string) *ScheduledTask {
+	t.name = name
+	return t
+}
+
+// Overlap 设置上次执行还没有结束时的处理策略，默认为 OverlapSkip 。
+func (t *ScheduledTask) Overlap(policy OverlapPolicy) *ScheduledTask {
+	t.overlap = policy
+	return t
+}
+
+// Schedule 注册定时任务，spec 的格式参考 ParseTrigger 的解释，可以使用 ${}
+// 引用属性。需要注意的是该方法在注入开始后就不能再调用了。
+func (c *container) Schedule(spec string, fn interface{}, args ...arg.Arg) *ScheduledTask {
+	t := newScheduledTask(spec, fn, args, nil)
+	c.tasks = append(c.tasks, t)
+	return t
+}
+
+// Schedule 为 bean 注册定时任务，fn 的第一个参数是 bean，其他参数和 spec 参考
+// Container.Schedule 的解释。bean 必须是单例，lazy bean 在容器刷新时创建，bean
+// 被条件排除时任务也不再执行。
+func (d *BeanDefinition) Schedule(spec string, fn interface{}, args ...arg.Arg) *ScheduledTask {
+	t := newScheduledTask(spec, fn, args, d)
+	d.tasks = append(d.tasks, t)
+	return t
+}
+
+// prepareTasks 收集有效 bean 的定时任务并解析所有任务的执行计划，在注入开始之前
+// 发现错误的配置。
+func (c *container) prepareTasks() error {
+
+	for _, b := range c.beans {
+		if b.status == Deleted || len(b.tasks) == 0 {
+			continue
+		}
+		if !b.isSingleton() {
+			return fmt.Errorf("scheduled task of %s should be singleton", b)
+		}
+		c.tasks = append(c.tasks, b.tasks...)
+	}
+
+	for _, t := range c.tasks {
+		spec, err := c.p.Resolve(t.spec)
+		if err != nil {
+			return fmt.Errorf("resolve schedule of task %s error: %w", t.name, err)
+		}
+		if t.trigger, err = ParseTrigger(spec); err != nil {
+			return fmt.Errorf("schedule of task %s error: %w", t.name, err)
+		}
+	}
+	return nil
+}
+
+// startTasks 在容器刷新之后启动所有的定时任务。
+func (c *container) startTasks() {
+	for _, t := range c.tasks {
+		c.runSchedule(t)
+	}
+}
+
+// runSchedule 在 goroutine 中按照执行计划触发任务，容器关闭时退出。执行时间已经
+// 错过的触发直接丢弃，从当前时间开始计算下次执行时间。
+func (c *container) runSchedule(t *ScheduledTask) {
+	trigger := t.trigger
+	clock := c.getClock()
+	c.goNamed("schedule "+t.name, func(ctx context.Context) {
+
+		_, delay := trigger.(fixedDelay)
+		next := trigger.Next(clock.Now())
+
+		for {
+			if next.IsZero() {
+				c.logger.Warnf("scheduled task %s has no next execution", t.name)
+				return
+			}
+
+			timer, stop := clock.NewTimer(next.Sub(clock.Now()))
+			select {
+			case <-ctx.Done():
+				stop()
+				return
+			case <-timer:
+			}
+
+			if delay {
+				c.executeTask(ctx, t)
+				next = trigger.Next(clock.Now())
+				continue
+			}
+
+			c.fireTask(t)
+			now := clock.Now()
+			if next = trigger.Next(next); next.Before(now) {
+				next = trigger.Next(now)
+			}
+		}
+	})
+}
+
+// fireTask 根据 OverlapPolicy 在新的 goroutine 中执行任务，排队等待的执行由上次
+// 执行的 goroutine 依次完成。
+func (c *container) fireTask(t *ScheduledTask) {
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.running > 0 {
+		switch t.overlap {
+		case OverlapSkip:
+			c.logger.Warnf("scheduled task %s skipped because the last execution is still running", t.name)
+			return
+		case OverlapQueue:
+			t.pending++
+			return
+		}
+	}
+
+	t.running++
+	c.goNamed(t.name, func(ctx context.Context) {
+		for {
+			c.executeTask(ctx, t)
+			t.mutex.Lock()
+			if t.pending == 0 || ctx.Err() != nil {
+				t.pending = 0
+				t.running--
+				t.mutex.Unlock()
+				return
+			}
+			t.pending--
+			t.mutex.Unlock()
+		}
+	})
+}
+
+// executeTask 执行一次任务，任务返回的 error 和发生的 panic 只记录日志。
+func (c *container) executeTask(ctx context.Context, t *ScheduledTask) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			c.logger.Panicf("scheduled task %s panic: %v", t.name, r)
+		}
+	}()
+
+	stack := newWiringStack(c.logger)
+	if _, err := t.r.Call(&taskArgContext{argContext{c: c, stack: stack}, ctx}); err != nil {
+		c.logger.Errorf("scheduled task %s returns error: %v", t.name, err)
+	}
+}
+
+// taskArgContext 为任务函数的 context.Context 类型的参数注入容器的 ctx 。
+type taskArgContext struct {
+	argContext
+	ctx context.Context
+}
+
+func (a *taskArgContext) Wire(v reflect.Value, tag string) error {
+	if tag == "" && util.IsContextType(v.Type()) {
+		v.Set(reflect.ValueOf(a.ctx))
+		return nil
+	}
+	return a.argContext.Wire(v, tag)
+}
@@ -0,0 +1,200 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/go-spring/spring-base/knife"
+)
+
+const (
+	SingletonScope = "singleton" // 单例，整个容器内只有一个实例
+	PrototypeScope = "prototype" // 原型，每个注入点或者每次获取都创建新的实例
+	RequestScope   = "request"   // 请求，每个 HTTP 请求内只有一个实例
+	RefreshScope   = "refresh"   // 刷新，绑定的属性发生变化时重新创建实例
+)
+
+// ObjectFactory 为 Scope 提供创建和销毁 bean 实例的能力。
+type ObjectFactory interface {
+
+	// Create 创建一个新的 bean 实例，并且完成属性绑定和依赖注入。
+	Create() (interface{}, error)
+
+	// Destroy 执行 bean 实例的销毁函数，Scope 在实例失效时负责调用。
+	Destroy(i interface{})
+}
+
+// Scope 决定 bean 实例何时创建以及在多大的范围内复用。singleton 作用域由容器
+// 直接管理，其他作用域在每次注入或者获取 bean 时都会通过 Scope 获取实例。
+type Scope interface {
+
+	// Get 返回 ctx 所在的作用域内 beanID 对应的实例，实例不存在时通过 factory
+	// 创建。容器刷新时注入的 ctx 是容器的 ctx，通过 ScopedProxy 获取实例时 ctx
+	// 是调用者传入的 ctx 。
+	Get(ctx context.Context, beanID string, factory ObjectFactory) (interface{}, error)
+}
+
+// prototypeScope 每次都创建新的实例，并且不负责实例的销毁。
+type prototypeScope struct{}
+
+func (s *prototypeScope) Get(ctx context.Context, beanID string, factory ObjectFactory) (interface{}, error) {
+	return factory.Create()
+}
+
+const requestBeansKey = "::RequestBeans::"
+
+// requestBeans 保存一个 HTTP 请求内创建的 bean 实例。
+type requestBeans struct {
+	mutex     sync.Mutex
+	beans     map[string]interface{}
+	destroyer []func()
+}
+
+// destroy 按照创建顺序的逆序销毁请求内创建的 bean 实例。
+func (r *requestBeans) destroy() {
+	r.mutex.Lock()
+	destroyer := r.destroyer
+	r.destroyer = nil
+	r.mutex.Unlock()
+	for i := len(destroyer) - 1; i >= 0; i-- {
+		destroyer[i]()
+	}
+}
+
+// beginRequest 在 ctx 上开启 request 作用域，返回值用于在请求结束时销毁请求内
+// 创建的 bean 实例。如果 ctx 上已经开启了 request 作用域，则返回一个空函数。
+func beginRequest(ctx context.Context) (func(), error) {
+	r := &requestBeans{beans: make(map[string]interface{})}
+	_, loaded, err := knife.LoadOrStore(ctx, requestBeansKey, r)
+	if err != nil {
+		return nil, err
+	}
+	if loaded {
+		return func() {}, nil
+	}
+	return r.destroy, nil
+}
+
+// requestScope 在每个 HTTP 请求内只创建一次实例，请求结束时销毁这些实例。
+type requestScope struct{}
+
+func (s *requestScope) Get(ctx context.Context, beanID string, factory ObjectFactory) (interface{}, error) {
+
+	v, _ := knife.Load(ctx, requestBeansKey)
+	r, ok := v.(*requestBeans)
+	if !ok {
+		return nil, errors.New("request scope is not active, use ScopedProxy in HTTP request")
+	}
+
+	r.mutex.Lock()
+	i, ok := r.beans[beanID]
+	r.mutex.Unlock()
+	if ok {
+		return i, nil
+	}
+
+	// 创建实例的时候不能持有锁，因为实例可能依赖其他 request 作用域的 bean 。
+	i, err := factory.Create()
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if prev, ok := r.beans[beanID]; ok {
+		factory.Destroy(i)
+		return prev, nil
+	}
+	r.beans[beanID] = i
+	r.destroyer = append(r.destroyer, func() { factory.Destroy(i) })
+	return i, nil
+}
+
+// ScopedProxy 是非 singleton 作用域的 bean 的代理。singleton bean 可以注入
+// *ScopedProxy 类型的字段，然后在运行时通过 ctx 获取 bean 在当前作用域内的实例，
+// 注入时必须通过 autowire 标签指定 bean 的名称或者类型。
+type ScopedProxy struct {
+	c *container
+	b *BeanDefinition
+}
+
+// BeanID 返回被代理的 bean 的 ID 。
+func (p *ScopedProxy) BeanID() string {
+	return p.b.ID()
+}
+
+// Get 返回 bean 在 ctx 所在的作用域内的实例。
+func (p *ScopedProxy) Get(ctx context.Context) (interface{}, error) {
+	v, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// Load 获取 bean 在 ctx 所在的作用域内的实例然后赋值给 i，i 是 bean 接收者的
+// 指针，因此不需要对 Get 的返回值进行类型断言，例如:
+//
+//	var u *User
+//	err := proxy.Load(ctx, &u)
+func (p *ScopedProxy) Load(ctx context.Context, i interface{}) error {
+
+	r := reflect.ValueOf(i)
+	if r.Kind() != reflect.Ptr || r.IsNil() {
+		return errors.New("i must be non-nil pointer")
+	}
+
+	v, err := p.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if e := r.Elem(); v.Type().AssignableTo(e.Type()) {
+		e.Set(v)
+		return nil
+	}
+	return fmt.Errorf("%s can't be assigned to %s", p.b, r.Elem().Type())
+}
+
+// get 在运行时创建或者获取 bean 的实例，实例的创建由容器的锁串行化，只有执行
+// 构造函数等用户代码时才会释放锁。
+func (p *ScopedProxy) get(ctx context.Context) (reflect.Value, error) {
+
+	stack := newWiringStack(p.c.logger)
+	stack.ctx = ctx
+
+	defer func() {
+		if len(stack.beans) > 0 {
+			p.c.logger.Infof("wiring path %s", stack.path())
+		}
+	}()
+
+	var v reflect.Value
+	err := p.c.runtimeWire(stack, func() (err error) {
+		if v, err = p.c.getScopedBean(p.b, stack); err != nil {
+			return err
+		}
+		p.c.saveLazyDestroyers(stack)
+		return nil
+	})
+	return v, err
+}
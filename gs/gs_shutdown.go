@@ -0,0 +1,273 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-spring/spring-base/util"
+)
+
+// SpringShutdownTimeout 关闭容器的超时时间，包括等待 goroutine 退出以及执行销毁
+// 函数的时间，例如 30s，没有设置时一直等待。
+const SpringShutdownTimeout = "spring.shutdown.timeout"
+
+// SpringShutdownGracePeriod 关闭超时之后每个剩下的销毁函数的执行时间，默认 1s 。
+const SpringShutdownGracePeriod = "spring.shutdown.grace.period"
+
+const defaultShutdownGracePeriod = time.Second
+
+// BeanDestroyWithContext 是可以接收关闭超时时间的 BeanDestroy，ctx 在关闭超时
+// 后发出 Done 信号，返回的 error 会汇总到关闭容器的结果中。
+type BeanDestroyWithContext interface {
+	OnDestroy(ctx context.Context) error
+}
+
+// beanDestroyer 是 bean 的销毁函数。
+type beanDestroyer struct {
+	bean string
+	fn   func(ctx context.Context) error
+}
+
+// destroyBeanValue 执行 bean 的销毁函数，fn 为空时执行 BeanDestroy 或者
+// BeanDestroyWithContext 接口。
+func destroyBeanValue(ctx context.Context, v reflect.Value, fn interface{}) error {
+
+	if fn == nil {
+		switch d := v.Interface().(type) {
+		case BeanDestroyWithContext:
+			return d.OnDestroy(ctx)
+		case BeanDestroy:
+			d.OnDestroy()
+		}
+		return nil
+	}
+
+	fnValue := reflect.ValueOf(fn)
+	in := []reflect.Value{v}
+	if fnValue.Type().NumIn() == 2 {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	out := fnValue.Call(in)
+	if len(out) > 0 && !out[0].IsNil() {
+		return out[0].Interface().(error)
+	}
+	return nil
+}
+
+// ShutdownError 汇总关闭容器时发生的所有错误，包括销毁函数返回的 error 以及没
+// 有在超时时间内退出的 goroutine 和销毁函数。
+type ShutdownError struct {
+	Errors []error
+}
+
+func (e *ShutdownError) Error() string {
+	var s []string
+	for _, err := range e.Errors {
+		s = append(s, err.Error())
+	}
+	return "shutdown error: " + strings.Join(s, "; ")
+}
+
+// shutdownContext 返回关闭容器时使用的 ctx，spring.shutdown.timeout 设置了超时
+// 时间时 ctx 在超时后发出 Done 信号。
+func shutdownContext(timeout string) (context.Context, context.CancelFunc, error) {
+	if timeout == "" {
+		ctx, cancel := context.WithCancel(context.Background())
+		return ctx, cancel, nil
+	}
+	d, err := time.ParseDuration(timeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s %q", SpringShutdownTimeout, timeout)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	return ctx, cancel, nil
+}
+
+// goroutines 记录正在运行的 goroutine，用于报告阻塞关闭的 goroutine 。
+type goroutines struct {
+	mutex   sync.Mutex
+	nextID  int
+	running map[int]string
+}
+
+func (g *goroutines) add(name string) int {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.running == nil {
+		g.running = make(map[int]string)
+	}
+	g.nextID++
+	g.running[g.nextID] = name
+	return g.nextID
+}
+
+func (g *goroutines) remove(id int) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.running, id)
+}
+
+// names 返回正在运行的 goroutine 的名称，按照启动顺序排列。
+func (g *goroutines) names() []string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	var ids []int
+	for id := range g.running {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var ret []string
+	for _, id := range ids {
+		ret = append(ret, g.running[id])
+	}
+	return ret
+}
+
+// goroutineName 返回 fn 的函数名及其所在的文件和行数。
+func goroutineName(fn interface{}) string {
+	file, line, name := util.FileLine(fn)
+	return fmt.Sprintf("%s %s:%d", name, file, line)
+}
+
+// closeContext 关闭容器，首先触发 ctx 的 Done 信号，然后等待所有 goroutine 结
+// 束，最后按照被依赖先销毁的原则执行所有的销毁函数。ctx 超时后不再等待没有退出
+// 的 goroutine 和没有返回的销毁函数，剩下的销毁函数仍然会执行，但是每个销毁函数
+// 只有 spring.shutdown.grace.period 的执行时间，这些 goroutine 和 bean 连同销
+// 毁函数返回的 error 一起返回。
+func (c *container) closeContext(ctx context.Context) []error {
+
+	var errs []error
+
+	c.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	select {
+	case <-done:
+		c.logger.Info("goroutines exited")
+	default:
+		for _, name := range c.goroutines.names() {
+			err := fmt.Errorf("goroutine %s blocked shutdown", name)
+			c.logger.Warn(err)
+			errs = append(errs, err)
+		}
+	}
+
+	// 运行时注入可能正在创建 lazy bean 或者 refresh 作用域的 bean，因此需要持有
+	// 容器的锁取出销毁函数和实例，然后在锁外执行销毁函数。
+	var refreshBeans map[string]*refreshBean
+	unlock := c.lock()
+	if s, ok := c.scopes[RefreshScope].(*refreshScope); ok {
+		refreshBeans = s.reset()
+	}
+	destroyers := c.destroyers
+	c.destroyers = nil
+	unlock()
+
+	// refresh 作用域的 bean 可能依赖 singleton bean，需要先销毁。
+	for _, r := range refreshBeans {
+		r.factory.Destroy(r.instance)
+	}
+
+	grace := c.gracePeriod()
+	for _, d := range destroyers {
+		var err error
+		if ctx.Err() == nil {
+			err = c.destroyBean(ctx, d)
+		} else {
+			c.logger.Warnf("destroy %s after shutdown timeout", d.bean)
+			graceCtx, cancel := context.WithTimeout(context.Background(), grace)
+			err = c.destroyBean(graceCtx, d)
+			cancel()
+		}
+		if err != nil {
+			c.logger.Error(err)
+			errs = append(errs, err)
+		}
+	}
+
+	c.logger.Info("container closed")
+	return errs
+}
+
+// gracePeriod 返回关闭超时之后每个销毁函数的执行时间。
+func (c *container) gracePeriod() time.Duration {
+	s := c.p.Get(SpringShutdownGracePeriod)
+	if s == "" {
+		return defaultShutdownGracePeriod
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		c.logger.Errorf("invalid %s %q", SpringShutdownGracePeriod, s)
+		return defaultShutdownGracePeriod
+	}
+	return d
+}
+
+// destroyBean 执行 bean 的销毁函数，ctx 超时后不再等待。销毁函数在 ctx 超时之
+// 后才返回也视为阻塞了关闭，这样等待 ctx 的 Done 信号的销毁函数的结果是确定的。
+func (c *container) destroyBean(ctx context.Context, d beanDestroyer) error {
+
+	blocked := fmt.Errorf("destroy %s blocked shutdown", d.bean)
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("destroy %s panic: %v", d.bean, r)
+			}
+		}()
+		err := d.fn(ctx)
+		if ctx.Err() != nil {
+			done <- blocked
+			return
+		}
+		if err != nil {
+			done <- fmt.Errorf("destroy %s error: %w", d.bean, err)
+			return
+		}
+		done <- nil
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		select {
+		case err := <-done:
+			return err
+		default:
+			return blocked
+		}
+	}
+}
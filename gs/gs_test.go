@@ -17,13 +17,18 @@
 package gs_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -38,6 +43,7 @@ import (
 	"github.com/go-spring/spring-core/gs/cond"
 	pkg1 "github.com/go-spring/spring-core/gs/testdata/pkg/bar"
 	pkg2 "github.com/go-spring/spring-core/gs/testdata/pkg/foo"
+	"github.com/go-spring/spring-core/web"
 )
 
 func init() {
@@ -2716,3 +2722,2194 @@ func TestContextAware(t *testing.T) {
 	a := b.Interface().(*ContextAware)
 	assert.Equal(t, a.Echo("gopher"), "hello gopher!")
 }
+
+type scopedCounter struct {
+	ID     int
+	Prefix string `value:"${prefix:=id}"`
+}
+
+type scopedCounterUser struct {
+	A *scopedCounter   `autowire:""`
+	B *scopedCounter   `autowire:""`
+	C []*scopedCounter `autowire:""`
+}
+
+type threadScope struct {
+	m map[string]interface{}
+}
+
+func (s *threadScope) Get(ctx context.Context, beanID string, factory gs.ObjectFactory) (interface{}, error) {
+	if i, ok := s.m[beanID]; ok {
+		return i, nil
+	}
+	i, err := factory.Create()
+	if err != nil {
+		return nil, err
+	}
+	s.m[beanID] = i
+	return i, nil
+}
+
+func TestApplicationContext_Scope(t *testing.T) {
+
+	t.Run("prototype constructor", func(t *testing.T) {
+		c := gs.New()
+		n := 0
+		c.Provide(func() *scopedCounter {
+			n++
+			return &scopedCounter{ID: n}
+		}).Scope(gs.PrototypeScope)
+		u := &scopedCounterUser{}
+		c.Object(u)
+		err := runTest(c, func(ctx gs.Context) {
+			var s1, s2 *scopedCounter
+			assert.Nil(t, ctx.Get(&s1))
+			assert.Nil(t, ctx.Get(&s2))
+			assert.True(t, s1.ID != s2.ID)
+			assert.Equal(t, s1.Prefix, "id")
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, n, 5)
+		assert.Equal(t, len(u.C), 1)
+		assert.True(t, u.A.ID != u.B.ID)
+		assert.True(t, u.B.ID != u.C[0].ID)
+	})
+
+	t.Run("prototype object", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&scopedCounter{ID: 5}).Scope(gs.PrototypeScope)
+		u := &scopedCounterUser{}
+		c.Object(u)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, u.A.ID, 5)
+		assert.Equal(t, u.B.ID, 5)
+		assert.True(t, u.A != u.B)
+	})
+
+	t.Run("custom scope", func(t *testing.T) {
+		c := gs.New()
+		c.RegisterScope("thread", &threadScope{m: map[string]interface{}{}})
+		c.Provide(func() *scopedCounter { return &scopedCounter{} }).Scope("thread")
+		u := &scopedCounterUser{}
+		c.Object(u)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.True(t, u.A == u.B)
+		assert.True(t, u.A == u.C[0])
+	})
+
+	t.Run("scope not found", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(func() *scopedCounter { return &scopedCounter{} }).Scope("thread")
+		err := c.Refresh()
+		assert.Error(t, err, "scope \"thread\" not found")
+	})
+
+	t.Run("prototype circle", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(func(u *scopedCounterUser) *scopedCounter { return &scopedCounter{} }).Scope(gs.PrototypeScope)
+		c.Object(&scopedCounterUser{}).Scope(gs.PrototypeScope)
+		c.Object(&struct {
+			U *scopedCounterUser `autowire:""`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "found circle autowire")
+	})
+}
+
+type requestUser struct {
+	Name      string `value:"${user.name:=anonymous}"`
+	destroyed bool
+}
+
+func (u *requestUser) OnDestroy() {
+	u.destroyed = true
+}
+
+type requestController struct {
+	User *gs.ScopedProxy `autowire:"requestUser"`
+}
+
+func TestApplicationContext_RequestScope(t *testing.T) {
+
+	c := gs.New()
+	n := 0
+	c.Provide(func() *requestUser {
+		n++
+		return &requestUser{}
+	}).Name("requestUser").Scope(gs.RequestScope)
+	ctrl := &requestController{}
+	c.Object(ctrl)
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	_, err = ctrl.User.Get(context.Background())
+	assert.Error(t, err, "request scope is not active")
+
+	var users []*requestUser
+	handler := web.FUNC(func(ctx web.Context) {
+		u1, err := ctrl.User.Get(ctx.Context())
+		assert.Nil(t, err)
+		u2, err := ctrl.User.Get(ctx.Context())
+		assert.Nil(t, err)
+		assert.True(t, u1 == u2)
+		assert.Equal(t, u1.(*requestUser).Name, "anonymous")
+		users = append(users, u1.(*requestUser))
+	})
+
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		w := &web.SimpleResponse{ResponseWriter: httptest.NewRecorder()}
+		ctx := web.NewBaseContext("/", handler, r, w)
+		chain := web.NewFilterChain([]web.Filter{gs.RequestScopeFilter(), web.HandlerFilter(handler)})
+		chain.Next(ctx, web.Recursive)
+	}
+
+	assert.Equal(t, n, 2)
+	assert.Equal(t, len(users), 2)
+	assert.True(t, users[0] != users[1])
+	assert.True(t, users[0].destroyed)
+	assert.True(t, users[1].destroyed)
+}
+
+type requestRepo struct {
+	created int32
+}
+
+type requestSession struct {
+	Repo *requestRepo `autowire:""`
+}
+
+func TestApplicationContext_RequestScopeConcurrent(t *testing.T) {
+
+	c := gs.New()
+	var repos, sessions int32
+	c.Provide(func() *requestRepo {
+		atomic.AddInt32(&repos, 1)
+		time.Sleep(5 * time.Millisecond)
+		return &requestRepo{}
+	}).Lazy()
+	c.Provide(func() *requestSession {
+		atomic.AddInt32(&sessions, 1)
+		return &requestSession{}
+	}).Name("session").Scope(gs.RequestScope)
+	ctrl := &struct {
+		Session *gs.ScopedProxy `autowire:"session"`
+	}{}
+	c.Object(ctrl)
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	var s *requestSession
+	err = ctrl.Session.Load(context.Background(), &s)
+	assert.Error(t, err, "request scope is not active")
+
+	var i *int
+	err = ctrl.Session.Load(context.Background(), i)
+	assert.Error(t, err, "i must be non-nil pointer")
+
+	const n = 20
+	results := make(chan *requestSession, n)
+	handler := web.FUNC(func(ctx web.Context) {
+		var s1, s2 *requestSession
+		assert.Nil(t, ctrl.Session.Load(ctx.Context(), &s1))
+		assert.Nil(t, ctrl.Session.Load(ctx.Context(), &s2))
+		assert.True(t, s1 == s2)
+		var repo *requestRepo
+		err := ctrl.Session.Load(ctx.Context(), &repo)
+		assert.Error(t, err, "can't be assigned to \\*gs_test.requestRepo")
+		results <- s1
+	})
+
+	for j := 0; j < n; j++ {
+		go func() {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			w := &web.SimpleResponse{ResponseWriter: httptest.NewRecorder()}
+			ctx := web.NewBaseContext("/", handler, r, w)
+			chain := web.NewFilterChain([]web.Filter{gs.RequestScopeFilter(), web.HandlerFilter(handler)})
+			chain.Next(ctx, web.Recursive)
+		}()
+	}
+
+	m := make(map[*requestSession]bool)
+	for j := 0; j < n; j++ {
+		s := <-results
+		assert.True(t, s.Repo != nil)
+		m[s] = true
+	}
+	assert.Equal(t, len(m), n)
+	assert.Equal(t, atomic.LoadInt32(&sessions), int32(n))
+	assert.Equal(t, atomic.LoadInt32(&repos), int32(1))
+}
+
+type greeter interface {
+	Greet(name string) string
+}
+
+type simpleGreeter struct {
+	Prefix string `value:"${prefix:=hello}"`
+}
+
+func (g *simpleGreeter) Greet(name string) string {
+	return g.Prefix + " " + name
+}
+
+type upperGreeter struct {
+	g greeter
+}
+
+func (g *upperGreeter) Greet(name string) string {
+	return strings.ToUpper(g.g.Greet(name))
+}
+
+type recordProcessor struct {
+	Before []string
+	After  []string
+}
+
+func (p *recordProcessor) BeforeInit(bean interface{}, beanName string) (interface{}, error) {
+	p.Before = append(p.Before, beanName)
+	return bean, nil
+}
+
+func (p *recordProcessor) AfterInit(bean interface{}, beanName string) (interface{}, error) {
+	p.After = append(p.After, beanName)
+	if g, ok := bean.(greeter); ok && beanName == "upper" {
+		return &upperGreeter{g: g}, nil
+	}
+	return bean, nil
+}
+
+type wrongProcessor struct{}
+
+func (p *wrongProcessor) BeforeInit(bean interface{}, beanName string) (interface{}, error) {
+	return "wrong", nil
+}
+
+func (p *wrongProcessor) AfterInit(bean interface{}, beanName string) (interface{}, error) {
+	return bean, nil
+}
+
+func TestApplicationContext_BeanPostProcessor(t *testing.T) {
+
+	t.Run("wrap bean", func(t *testing.T) {
+		c := gs.New()
+		p := &recordProcessor{}
+		c.Object(p)
+		c.Provide(func() greeter { return &simpleGreeter{} }).Name("upper")
+		c.Object(&simpleGreeter{}).Name("plain")
+		s := &struct {
+			Upper greeter        `autowire:"upper"`
+			Plain *simpleGreeter `autowire:"plain"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Upper.Greet("gopher"), "HELLO GOPHER")
+		assert.Equal(t, s.Plain.Greet("gopher"), "hello gopher")
+		sort.Strings(p.Before)
+		sort.Strings(p.After)
+		assert.True(t, len(p.Before) > 2)
+		assert.Equal(t, p.Before, p.After)
+	})
+
+	t.Run("not assignable", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&wrongProcessor{})
+		c.Object(&simpleGreeter{})
+		err := c.Refresh()
+		assert.Error(t, err, "post processor returns string which is not assignable")
+	})
+}
+
+type calcService interface {
+	Add(a, b int) (int, error)
+	Name() string
+}
+
+type calcServiceImpl struct{}
+
+func (s *calcServiceImpl) Add(a, b int) (int, error) {
+	if a < 0 || b < 0 {
+		return 0, errors.New("negative number")
+	}
+	return a + b, nil
+}
+
+func (s *calcServiceImpl) Name() string {
+	return "calc"
+}
+
+type calcServiceProxy struct {
+	h gs.InvocationHandler
+}
+
+func (p *calcServiceProxy) Add(a, b int) (int, error) {
+	out := p.h.Invoke("Add", a, b)
+	err, _ := out[1].(error)
+	return out[0].(int), err
+}
+
+func (p *calcServiceProxy) Name() string {
+	return p.h.Invoke("Name")[0].(string)
+}
+
+func init() {
+	gs.RegisterProxy((*calcService)(nil), func(h gs.InvocationHandler) interface{} {
+		return &calcServiceProxy{h: h}
+	})
+}
+
+func TestApplicationContext_Intercept(t *testing.T) {
+
+	c := gs.New()
+	c.Object(&calcServiceImpl{}).Export((*calcService)(nil))
+
+	var calls []string
+	c.Intercept((*calcService)(nil), func(inv gs.Invocation) ([]interface{}, error) {
+		calls = append(calls, fmt.Sprint(inv.Method(), inv.Args()))
+		return inv.Proceed()
+	})
+	c.Intercept("calcServiceImpl", func(inv gs.Invocation) ([]interface{}, error) {
+		if inv.Method() != "Add" {
+			return inv.Proceed()
+		}
+		out, err := inv.Proceed()
+		if err != nil {
+			return []interface{}{-1}, nil
+		}
+		return []interface{}{out[0].(int) * 10}, nil
+	})
+
+	s := &struct {
+		Service calcService      `autowire:""`
+		Impl    *calcServiceImpl `autowire:""`
+		List    []calcService    `autowire:""`
+	}{}
+	c.Object(s)
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	assert.Equal(t, s.Impl.Name(), "calc")
+	assert.Equal(t, len(calls), 0)
+
+	r, err := s.Service.Add(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, r, 30)
+
+	r, err = s.Service.Add(-1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, r, -1)
+
+	assert.Equal(t, s.Service.Name(), "calc")
+	assert.Equal(t, calls, []string{"Add[1 2]", "Add[-1 2]", "Name[]"})
+
+	assert.Equal(t, len(s.List), 1)
+	assert.True(t, s.List[0] == s.Service)
+
+	t.Run("no proxy", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&simpleGreeter{}).Export((*greeter)(nil))
+		c.Intercept((*greeter)(nil), func(inv gs.Invocation) ([]interface{}, error) {
+			return inv.Proceed()
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "no proxy registered for gs_test.greeter")
+	})
+
+	t.Run("no export", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&calcServiceImpl{})
+		c.Intercept("calcServiceImpl", func(inv gs.Invocation) ([]interface{}, error) {
+			return inv.Proceed()
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "intercepted bean should export interfaces")
+	})
+}
+
+type orderCreatedEvent struct {
+	ID int
+}
+
+func (e *orderCreatedEvent) String() string {
+	return "order " + strconv.Itoa(e.ID)
+}
+
+type orderService struct {
+	GSContext gs.Context `autowire:""`
+}
+
+func (s *orderService) Create(id int) error {
+	return s.GSContext.Publish(&orderCreatedEvent{ID: id})
+}
+
+func TestApplicationContext_Publish(t *testing.T) {
+
+	c := gs.New()
+	s := &orderService{}
+	c.Object(s)
+
+	var (
+		syncIDs  []int
+		names    []string
+		asyncIDs = make(chan int, 3)
+	)
+
+	c.Listen(gs.NewEventListener(func(ctx context.Context, e *orderCreatedEvent) error {
+		if e.ID < 0 {
+			return errors.New("invalid order id")
+		}
+		syncIDs = append(syncIDs, e.ID)
+		return nil
+	})).Order(1)
+	c.Listen(gs.NewEventListener(func(ctx context.Context, e fmt.Stringer) error {
+		names = append(names, e.String())
+		return nil
+	})).Order(2)
+	c.Listen(gs.NewEventListener(func(ctx context.Context, e *orderCreatedEvent) error {
+		asyncIDs <- e.ID
+		return nil
+	}).Async())
+
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	assert.Nil(t, s.Create(1))
+	assert.Nil(t, s.Create(2))
+	assert.Error(t, s.Create(-1), "invalid order id")
+	assert.Nil(t, s.GSContext.Publish("unknown event"))
+
+	c.Close()
+	close(asyncIDs)
+
+	assert.Equal(t, syncIDs, []int{1, 2})
+	assert.Equal(t, names, []string{"order 1", "order 2"})
+	sum := 0
+	for id := range asyncIDs {
+		sum += id
+	}
+	assert.Equal(t, sum, 2)
+
+	assert.Panic(t, func() {
+		gs.NewEventListener(func(e *orderCreatedEvent) {})
+	}, "listener should be func\\(ctx context.Context, event\\)error")
+
+	t.Run("same function", func(t *testing.T) {
+		c := gs.New()
+		var ids []int
+		fn := func(ctx context.Context, e *orderCreatedEvent) error {
+			ids = append(ids, e.ID)
+			return nil
+		}
+		b1 := c.Listen(gs.NewEventListener(fn))
+		b2 := c.Listen(gs.NewEventListener(fn))
+		assert.Equal(t, b2.BeanName(), b1.BeanName()+"#1")
+		var ctx gs.Context
+		assert.Nil(t, runTest(c, func(p gs.Context) { ctx = p }))
+		assert.Nil(t, ctx.Publish(&orderCreatedEvent{ID: 3}))
+		assert.Equal(t, ids, []int{3, 3})
+	})
+	t.Run("publish on init", func(t *testing.T) {
+		c := gs.New()
+		var ids []int
+		c.Listen(gs.NewEventListener(func(ctx context.Context, e *orderCreatedEvent) error {
+			ids = append(ids, e.ID)
+			return nil
+		}))
+		c.Object(&initPublisher{})
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, ids, []int{7})
+	})
+
+	t.Run("publish after close", func(t *testing.T) {
+		c := gs.New()
+		s := &orderService{}
+		c.Object(s)
+		c.Listen(gs.NewEventListener(func(ctx context.Context, e *orderCreatedEvent) error {
+			return nil
+		}).Async())
+		err := c.Refresh()
+		assert.Nil(t, err)
+		c.Close()
+		assert.Error(t, s.Create(1), "container is closing")
+	})
+}
+
+// initPublisher 在初始化时发布事件，BeanPostProcessor 在其他 bean 之前创建。
+type initPublisher struct{}
+
+func (p *initPublisher) OnInit(ctx gs.Context) error {
+	return ctx.Publish(&orderCreatedEvent{ID: 7})
+}
+
+func (p *initPublisher) BeforeInit(bean interface{}, beanName string) (interface{}, error) {
+	return bean, nil
+}
+
+func (p *initPublisher) AfterInit(bean interface{}, beanName string) (interface{}, error) {
+	return bean, nil
+}
+
+type parallelDB struct {
+	destroyed *[]string
+}
+
+func (db *parallelDB) OnDestroy() {
+	*db.destroyed = append(*db.destroyed, "db")
+}
+
+type parallelCache struct{}
+
+type parallelService struct {
+	DB        *parallelDB    `autowire:""`
+	Cache     *parallelCache `autowire:""`
+	destroyed *[]string
+}
+
+func (s *parallelService) OnDestroy() {
+	*s.destroyed = append(*s.destroyed, "service")
+}
+
+type parallelRunner struct {
+	Cache *parallelCache
+}
+
+func (r *parallelRunner) OnInit(ctx gs.Context) error {
+	return ctx.Get(&r.Cache)
+}
+
+func TestApplicationContext_ParallelRefresh(t *testing.T) {
+
+	// 两个构造函数互相等待对方开始执行，只有并行创建时才能成功。
+	handshake := func(self, other chan struct{}) error {
+		close(self)
+		select {
+		case <-other:
+			return nil
+		case <-time.After(3 * time.Second):
+			return errors.New("beans are not created in parallel")
+		}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		dbStarted := make(chan struct{})
+		cacheStarted := make(chan struct{})
+		var destroyed []string
+
+		c := gs.New()
+		c.Property(gs.SpringRefreshParallel, true)
+		c.Provide(func() (*parallelDB, error) {
+			return &parallelDB{destroyed: &destroyed}, handshake(dbStarted, cacheStarted)
+		})
+		c.Provide(func() (*parallelCache, error) {
+			return &parallelCache{}, handshake(cacheStarted, dbStarted)
+		})
+		s := &parallelService{destroyed: &destroyed}
+		c.Object(s)
+		r := &parallelRunner{}
+		c.Object(r)
+
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.NotNil(t, s.DB)
+		assert.NotNil(t, s.Cache)
+		assert.Equal(t, r.Cache, s.Cache)
+
+		c.Close()
+		assert.Equal(t, destroyed, []string{"service", "db"})
+	})
+
+	t.Run("error", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			c := gs.New()
+			c.Property(gs.SpringRefreshParallel, true)
+			c.Provide(func() (*parallelDB, error) {
+				return nil, errors.New("db error")
+			})
+			c.Provide(func() (*parallelCache, error) {
+				return nil, errors.New("cache error")
+			})
+			c.Object(&parallelService{})
+			err := c.Refresh()
+			assert.Error(t, err, "cache error")
+		}
+	})
+	t.Run("skipped dependents", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringRefreshParallel, true)
+		c.Provide(func() (*parallelDB, error) {
+			return nil, errors.New("db error")
+		})
+		c.Provide(func() *parallelCache {
+			return &parallelCache{}
+		})
+		c.Object(&parallelService{})
+		err := c.Refresh()
+		assert.Error(t, err, `db error, skipped dependent beans \[github.com/go-spring/spring-core/gs/gs_test.parallelService:parallelService\]`)
+	})
+}
+
+type graphRepo struct{}
+
+type graphService struct {
+	Repo *graphRepo `autowire:""`
+}
+
+type graphHandler struct {
+	service *graphService
+}
+
+func TestApplicationContext_Graph(t *testing.T) {
+
+	c := gs.New()
+	c.Object(&graphRepo{}).Name("repo")
+	c.Object(&graphService{}).Name("service").DependsOn("repo")
+	c.Provide(func(s *graphService) *graphHandler {
+		return &graphHandler{service: s}
+	}).Name("handler")
+	c.Object(&graphRepo{}).Name("unused").On(cond.Not(cond.OK()))
+
+	assert.Nil(t, c.(gs.Context).Graph())
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	g := c.(gs.Context).Graph()
+	nodes := make(map[string]gs.BeanNode)
+	for _, n := range g.Beans {
+		nodes[n.ID] = n
+	}
+	assert.Equal(t, len(nodes), 5)
+	assert.True(t, nodes["github.com/go-spring/spring-core/gs/gs_test.graphRepo:unused"].Deleted)
+	assert.Equal(t, nodes["github.com/go-spring/spring-core/gs/gs_test.graphRepo:unused"].Condition, "unmatched")
+	assert.Equal(t, nodes["github.com/go-spring/spring-core/gs/gs.container:container"].Exports, []string{"gs.Context"})
+
+	const (
+		repo    = "github.com/go-spring/spring-core/gs/gs_test.graphRepo:repo"
+		service = "github.com/go-spring/spring-core/gs/gs_test.graphService:service"
+		handler = "github.com/go-spring/spring-core/gs/gs_test.graphHandler:handler"
+	)
+	var edges []gs.BeanEdge
+	for _, e := range g.Edges {
+		if _, ok := nodes[e.From]; ok && e.From != "github.com/go-spring/spring-core/gs/gs.container:container" {
+			edges = append(edges, e)
+		}
+	}
+	assert.Equal(t, edges, []gs.BeanEdge{
+		{From: handler, To: service, Kind: gs.ArgEdge},
+		{From: service, To: repo, Kind: gs.DependsEdge},
+		{From: service, To: repo, Kind: gs.FieldEdge, Field: "graphService.Repo"},
+	})
+
+	b, err := g.JSON()
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(b), `"kind": "depends"`))
+
+	dot := g.DOT()
+	assert.True(t, strings.HasPrefix(dot, "digraph beans {\n"))
+	assert.True(t, strings.Contains(dot, fmt.Sprintf("%q -> %q [label=\"graphService.Repo\"];", service, repo)))
+	assert.True(t, strings.Contains(dot, "style=dashed"))
+}
+
+func TestApplicationContext_ConditionReport(t *testing.T) {
+
+	c := gs.New()
+	c.Property("cache.enable", false)
+	c.Object(&graphRepo{}).Name("repo").On(cond.OnProperty("cache.enable", cond.HavingValue("true")))
+	c.Object(&graphHandler{}).Name("fallback").On(cond.OnMissingBean("repo"))
+	c.Provide((*graphRepo).Handler, "repo").Name("method")
+
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	report := c.(gs.Context).ConditionReport()
+	assert.Equal(t, len(report), 3)
+
+	assert.Equal(t, report[0].ID, "github.com/go-spring/spring-core/gs/gs_test.graphHandler:fallback")
+	assert.True(t, report[0].Matched)
+	assert.Equal(t, report[0].Result.String(), `OnMissingBean(selector=repo) matched, found 0 beans []`)
+
+	assert.Equal(t, report[1].ID, "github.com/go-spring/spring-core/gs/gs_test.graphHandler:method")
+	assert.False(t, report[1].Matched)
+	assert.Equal(t, report[1].Message, "parent bean repo not found")
+	assert.Nil(t, report[1].Result)
+
+	assert.Equal(t, report[2].ID, "github.com/go-spring/spring-core/gs/gs_test.graphRepo:repo")
+	assert.False(t, report[2].Matched)
+	assert.Equal(t, report[2].Result.String(), `OnProperty(name=cache.enable, havingValue=true) unmatched, cache.enable="false"`)
+}
+
+func (r *graphRepo) Handler() *graphHandler {
+	return &graphHandler{}
+}
+
+type timelineStore struct{}
+
+type timelineService struct {
+	DB *timelineStore `autowire:""`
+}
+
+func (s *timelineService) OnInit(ctx gs.Context) error {
+	time.Sleep(10 * time.Millisecond)
+	return nil
+}
+
+func TestApplicationContext_Timeline(t *testing.T) {
+
+	c := gs.New()
+	c.Provide(func() *timelineStore {
+		time.Sleep(20 * time.Millisecond)
+		return &timelineStore{}
+	}).Name("db")
+	c.Object(&timelineService{}).Name("service")
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	timeline := c.(gs.Context).Timeline()
+	steps := timeline.Steps()
+	assert.Equal(t, len(steps), 1)
+	assert.Equal(t, steps[0].Name, "refresh")
+	assert.Equal(t, len(steps[0].Children), 2)
+	assert.Equal(t, steps[0].Children[0].Name, "resolve")
+	assert.Equal(t, steps[0].Children[1].Name, "wire")
+
+	const (
+		db      = "github.com/go-spring/spring-core/gs/gs_test.timelineStore:db"
+		service = "github.com/go-spring/spring-core/gs/gs_test.timelineService:service"
+	)
+
+	// service 的依赖注入步骤包含了 db 的创建步骤。
+	s := timeline.Find(service)
+	assert.Equal(t, s.Bean, service)
+	var names []string
+	for _, child := range s.Children {
+		names = append(names, child.Name)
+	}
+	assert.Equal(t, names, []string{"wiring", "init"})
+	assert.Equal(t, s.Children[0].Children[0].Name, db)
+	assert.True(t, s.Children[1].Duration >= 10*time.Millisecond)
+
+	d := timeline.Find(db)
+	assert.Equal(t, d.Children[0].Name, "constructor")
+	assert.True(t, d.Children[0].Duration >= 20*time.Millisecond)
+
+	// service 的耗时包含了 db 的耗时，但是自身的耗时比 db 少。
+	assert.True(t, s.Duration > d.Duration)
+	assert.True(t, s.SelfDuration < d.SelfDuration)
+	assert.True(t, s.SelfDuration >= 10*time.Millisecond)
+	assert.Equal(t, s.SelfDuration, s.Duration-d.Duration)
+
+	beans := timeline.Beans()
+	assert.Equal(t, beans[0].Bean, db)
+	assert.Equal(t, beans[1].Bean, service)
+
+	b, err := timeline.JSON()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(string(b), `{"name":"startup","value":`))
+	assert.True(t, strings.Contains(string(b), `{"name":"constructor","value":`))
+	t.Run("parallel", func(t *testing.T) {
+		c := gs.New()
+		c.Property(gs.SpringRefreshParallel, true)
+		for _, name := range []string{"db1", "db2", "db3"} {
+			c.Provide(func() *timelineStore {
+				time.Sleep(20 * time.Millisecond)
+				return &timelineStore{}
+			}).Name(name)
+		}
+		err := c.Refresh()
+		assert.Nil(t, err)
+
+		// 并行创建的 bean 的耗时相互重叠，不能直接相加。
+		w := c.(gs.Context).Timeline().Find("wire")
+		assert.True(t, w.SelfDuration >= 0)
+		assert.True(t, w.SelfDuration <= w.Duration)
+	})
+}
+
+type childDB struct {
+	closed bool
+}
+
+type childCache struct {
+	DB *childDB `autowire:""`
+}
+
+type childTenant struct {
+	gs.ContextAware
+	DB     *childDB `autowire:""`
+	Name   string   `value:"${tenant.name}"`
+	Region string   `value:"${region}"`
+	closed bool
+}
+
+func TestApplicationContext_Child(t *testing.T) {
+
+	parent := gs.New()
+	parent.Property("region", "eu")
+	parent.Property("tenant.name", "shared")
+	db := &childDB{}
+	parent.Object(db).Destroy(func(db *childDB) { db.closed = true })
+
+	child := parent.NewChild()
+	child.Property("tenant.name", "acme")
+	tenant := &childTenant{}
+	child.Object(tenant).Destroy(func(t *childTenant) { t.closed = true })
+
+	err := child.Refresh()
+	assert.Error(t, err, "parent container should be refreshed first")
+
+	err = parent.Refresh()
+	assert.Nil(t, err)
+
+	child = parent.NewChild()
+	child.Property("tenant.name", "acme")
+	child.Object(tenant).Destroy(func(t *childTenant) { t.closed = true })
+	child.Object(&childCache{}).On(cond.OnBean((*childDB)(nil)))
+	err = child.Refresh()
+	assert.Nil(t, err)
+
+	assert.Equal(t, tenant.DB, db)
+	assert.Equal(t, tenant.Name, "acme")
+	assert.Equal(t, tenant.Region, "eu")
+
+	var dbs []*childDB
+	err = tenant.GSContext.Get(&dbs)
+	assert.Nil(t, err)
+	assert.Equal(t, dbs, []*childDB{db})
+
+	// 子容器的条件判断也可以找到父容器中的 bean 。
+	var cache *childCache
+	err = tenant.GSContext.Get(&cache)
+	assert.Nil(t, err)
+	assert.Equal(t, cache.DB, db)
+
+	// 父容器中看不到子容器的 bean 。
+	err = parent.(gs.Context).Get(new(*childTenant))
+	assert.Error(t, err, "can't find bean")
+
+	child.Close()
+	assert.True(t, tenant.closed)
+	assert.False(t, db.closed)
+	select {
+	case <-child.Context().Done():
+	default:
+		t.Fatal("child context should be done")
+	}
+	select {
+	case <-parent.Context().Done():
+		t.Fatal("parent context should not be done")
+	default:
+	}
+
+	parent.Close()
+	assert.True(t, db.closed)
+}
+
+type childContext struct {
+	gs.ContextAware
+}
+
+func TestApplicationContext_ChildCollection(t *testing.T) {
+
+	parent := gs.New()
+	parent.Object(&childDB{}).Name("a")
+	parent.Object(&childDB{}).Name("b")
+
+	// 子容器需要在父容器刷新之前创建。
+	auto := parent.NewChild()
+	auto.Object(&childDB{}).Name("b")
+	auto.Object(&childDB{}).Name("c")
+	autoCtx := &childContext{}
+	auto.Object(autoCtx)
+
+	assign := parent.NewChild()
+	assign.Object(&childDB{}).Name("c")
+	assignCtx := &childContext{}
+	assign.Object(assignCtx)
+
+	err := parent.Refresh()
+	assert.Nil(t, err)
+	defer parent.Close()
+
+	t.Run("auto", func(t *testing.T) {
+		err := auto.Refresh()
+		assert.Nil(t, err)
+		defer auto.Close()
+
+		ctx := autoCtx.GSContext
+		var local []*childDB
+		assert.Nil(t, ctx.Get(&local, "b", "c"))
+
+		// 自动模式下父容器的 bean 排在当前容器的 bean 之后。
+		var dbs []*childDB
+		assert.Nil(t, ctx.Get(&dbs))
+		assert.Equal(t, len(dbs), 4)
+		assert.Equal(t, dbs[:2], local)
+
+		// 同名的 bean 以当前容器为准。
+		var m map[string]*childDB
+		assert.Nil(t, ctx.Get(&m))
+		assert.Equal(t, len(m), 3)
+		assert.Equal(t, m["b"], local[0])
+		for _, db := range dbs[2:] {
+			assert.True(t, m["b"] != db)
+		}
+	})
+
+	t.Run("assign", func(t *testing.T) {
+		err := assign.Refresh()
+		assert.Nil(t, err)
+		defer assign.Close()
+
+		ctx := assignCtx.GSContext
+
+		// 当前容器中没有找到任何 bean 时从父容器中查找。
+		var dbs []*childDB
+		assert.Nil(t, ctx.Get(&dbs, "a", "b"))
+		assert.Equal(t, len(dbs), 2)
+
+		// 当前容器中找到了部分 bean 时不再从父容器中查找。
+		err = ctx.Get(&dbs, "a", "c")
+		assert.Error(t, err, "can't find bean, bean:\"a\"")
+
+		err = ctx.Get(&dbs, "a?", "c")
+		assert.Nil(t, err)
+		assert.Equal(t, len(dbs), 1)
+	})
+}
+
+type poolSizer interface {
+	Size() int
+}
+
+type refreshPool struct {
+	size   int
+	Name   string `value:"${pool.name:=default}"`
+	closed bool
+}
+
+func (p *refreshPool) Size() int {
+	return p.size
+}
+
+type poolSizerProxy struct {
+	h gs.InvocationHandler
+}
+
+func (p *poolSizerProxy) Size() int {
+	return p.h.Invoke("Size")[0].(int)
+}
+
+func init() {
+	gs.RegisterProxy((*poolSizer)(nil), func(h gs.InvocationHandler) interface{} {
+		return &poolSizerProxy{h: h}
+	})
+}
+
+func TestApplicationContext_RefreshScope(t *testing.T) {
+
+	c := gs.New()
+	c.Property("pool.size", 10)
+	c.Property("other", "a")
+
+	var pools []*refreshPool
+	c.Provide(func(size int) (*refreshPool, error) {
+		if size < 0 {
+			return nil, errors.New("invalid pool size")
+		}
+		p := &refreshPool{size: size}
+		pools = append(pools, p)
+		return p, nil
+	}, "${pool.size}").Scope(gs.RefreshScope).Export((*poolSizer)(nil)).Destroy(func(p *refreshPool) {
+		p.closed = true
+	})
+
+	s := &struct {
+		Pool poolSizer `autowire:""`
+	}{}
+	c.Object(s)
+	err := c.Refresh()
+	assert.Nil(t, err)
+
+	pool := s.Pool
+	assert.Equal(t, pool.Size(), 10)
+	assert.Equal(t, pool.Size(), 10)
+	assert.Equal(t, len(pools), 1)
+
+	// 无关的属性发生变化时不重新创建。
+	err = c.Properties().Update(map[string]interface{}{"other": "b"})
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Size(), 10)
+	assert.Equal(t, len(pools), 1)
+
+	// 过期的实例在新的实例创建成功后才销毁。
+	err = c.Properties().Update(map[string]interface{}{"pool.size": 20})
+	assert.Nil(t, err)
+	assert.False(t, pools[0].closed)
+	assert.True(t, s.Pool == pool)
+	assert.Equal(t, pool.Size(), 20)
+	assert.Equal(t, len(pools), 2)
+	assert.True(t, pools[0].closed)
+
+	// 重新创建失败时继续使用过期的实例。
+	err = c.Properties().Update(map[string]interface{}{"pool.size": -1})
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Size(), 20)
+	assert.Equal(t, len(pools), 2)
+	assert.False(t, pools[1].closed)
+
+	err = c.Properties().Update(map[string]interface{}{"pool.size": 20})
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Size(), 20)
+	assert.Equal(t, len(pools), 3)
+	assert.True(t, pools[1].closed)
+
+	p := conf.New()
+	_ = p.Set("pool.size", 20)
+	_ = p.Set("pool.name", "hot")
+	_ = p.Set("other", "b")
+	err = c.Properties().Refresh(p)
+	assert.Nil(t, err)
+	assert.Equal(t, pool.Size(), 20)
+	assert.Equal(t, len(pools), 4)
+	assert.Equal(t, pools[3].Name, "hot")
+
+	c.Close()
+	assert.True(t, pools[3].closed)
+
+	t.Run("no proxy", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&refreshPool{}).Scope(gs.RefreshScope)
+		c.Object(&struct {
+			Pool *refreshPool `autowire:""`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "refresh scope bean should be injected into an interface registered by RegisterProxy")
+	})
+}
+
+type lazyReport struct {
+	created bool
+	closed  bool
+}
+
+type lazyCalc struct {
+	calcServiceImpl
+}
+
+type lazyAdmin struct {
+	Report *lazyReport `autowire:""`
+}
+
+func TestApplicationContext_Lazy(t *testing.T) {
+
+	c := gs.New()
+
+	var created []string
+	c.Provide(func() *lazyReport {
+		created = append(created, "report")
+		return &lazyReport{created: true}
+	}).Lazy().Destroy(func(r *lazyReport) { r.closed = true })
+	c.Provide(func() *lazyCalc {
+		created = append(created, "calc")
+		return &lazyCalc{}
+	}).Name("lazyCalc").Lazy().Export((*calcService)(nil))
+	c.Provide(func() *lazyAdmin {
+		created = append(created, "admin")
+		return &lazyAdmin{}
+	}).Lazy()
+
+	s := &struct {
+		gs.ContextAware
+		Calc calcService `autowire:"lazyCalc"`
+	}{}
+	c.Object(s)
+	err := c.Refresh()
+	assert.Nil(t, err)
+	assert.Equal(t, len(created), 0)
+
+	// 注入到接口的 lazy bean 在第一次调用时创建。
+	assert.Equal(t, s.Calc.Name(), "calc")
+	assert.Equal(t, created, []string{"calc"})
+	r, err := s.Calc.Add(1, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, r, 3)
+	assert.Equal(t, created, []string{"calc"})
+
+	// 获取 lazy bean 时才创建，同时创建它依赖的 lazy bean 。
+	var admin *lazyAdmin
+	err = s.GSContext.Get(&admin)
+	assert.Nil(t, err)
+	assert.True(t, admin.Report.created)
+	assert.Equal(t, created, []string{"calc", "admin", "report"})
+
+	c.Close()
+	assert.True(t, admin.Report.closed)
+
+	t.Run("close concurrently", func(t *testing.T) {
+		c := gs.New()
+		var closed int32
+		c.Provide(func() *lazyCalc {
+			return &lazyCalc{}
+		}).Lazy().Export((*calcService)(nil)).Destroy(func(*lazyCalc) {
+			atomic.AddInt32(&closed, 1)
+		})
+		s := &struct {
+			Calc calcService `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+
+		// 运行时创建 lazy bean 和关闭容器同时进行时不会发生数据竞争。
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_ = s.Calc.Name()
+			}()
+		}
+		c.Close()
+		wg.Wait()
+		assert.True(t, atomic.LoadInt32(&closed) <= 1)
+	})
+	t.Run("constructor error", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(func() (*lazyCalc, error) {
+			return nil, errors.New("calc unavailable")
+		}).Lazy().Export((*calcService)(nil))
+		s := &struct {
+			Calc calcService `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		defer c.Close()
+
+		// 方法有 error 返回值时通过它返回创建 bean 的错误。
+		r, err := s.Calc.Add(1, 2)
+		assert.Error(t, err, "calc unavailable")
+		assert.Equal(t, r, 0)
+
+		// 方法没有 error 返回值时 panic 。
+		assert.Panic(t, func() { s.Calc.Name() }, "calc unavailable")
+	})
+}
+
+func TestParseTrigger(t *testing.T) {
+
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		util.Panic(err).When(err != nil)
+		return v
+	}
+
+	testcases := []struct {
+		spec string
+		from string
+		next string
+	}{
+		{"*/15 * * * * *", "2021-06-01 10:00:07", "2021-06-01 10:00:15"},
+		{"0 30 9 * * MON-FRI", "2021-06-04 10:00:00", "2021-06-07 09:30:00"},
+		{"0 0 1,15 * *", "2021-06-01 00:00:00", "2021-06-15 00:00:00"},
+		{"0 0 * JAN *", "2021-06-01 00:00:00", "2022-01-01 00:00:00"},
+		{"0 0 13 * 5", "2021-06-01 00:00:00", "2021-06-04 00:00:00"},
+		{"0 0 0 29 2 ?", "2021-03-01 00:00:00", "2024-02-29 00:00:00"},
+		{"@daily", "2021-06-01 10:00:00", "2021-06-02 00:00:00"},
+		{"@every 90s", "2021-06-01 10:00:00", "2021-06-01 10:01:30"},
+		{"@delay 1m", "2021-06-01 10:00:00", "2021-06-01 10:01:00"},
+	}
+
+	for _, c := range testcases {
+		r, err := gs.ParseTrigger(c.spec)
+		assert.Nil(t, err)
+		assert.Equal(t, r.Next(at(c.from)), at(c.next))
+	}
+
+	r, err := gs.ParseTrigger("0 0 0 30 2 *")
+	assert.Nil(t, err)
+	assert.True(t, r.Next(at("2021-01-01 00:00:00")).IsZero())
+
+	for _, spec := range []string{"", "* * * *", "61 * * * *", "@every", "@every -1s", "@often", "5-1 * * * *"} {
+		_, err = gs.ParseTrigger(spec)
+		assert.NotNil(t, err)
+	}
+}
+
+type scheduledCounter struct {
+	count int32
+	ticks chan struct{}
+}
+
+func (c *scheduledCounter) Tick(ctx context.Context, step int32) error {
+	if ctx == nil {
+		return errors.New("ctx should not be nil")
+	}
+	atomic.AddInt32(&c.count, step)
+	c.ticks <- struct{}{}
+	return nil
+}
+
+func (c *scheduledCounter) get() int32 {
+	return atomic.LoadInt32(&c.count)
+}
+
+// manualClock 是手动推进时间的 gs.Clock，使定时任务的测试不依赖真实的时间。
+type manualClock struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	now    time.Time
+	timers map[*manualTimer]bool
+}
+
+type manualTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newManualClock() *manualClock {
+	c := &manualClock{
+		now:    time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC),
+		timers: make(map[*manualTimer]bool),
+	}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *manualClock) NewTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	t := &manualTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers[t] = true
+	c.cond.Broadcast()
+	return t.c, func() bool {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		ok := c.timers[t]
+		delete(c.timers, t)
+		return ok
+	}
+}
+
+// wait 等待 n 个计时器开始计时。
+func (c *manualClock) wait(n int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.timers) < n {
+		c.cond.Wait()
+	}
+}
+
+// advance 推进时间并触发到期的计时器。
+func (c *manualClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+	for t := range c.timers {
+		if !t.at.After(c.now) {
+			delete(c.timers, t)
+			t.c <- c.now
+		}
+	}
+}
+
+func TestApplicationContext_Schedule(t *testing.T) {
+
+	t.Run("fixed rate and delay", func(t *testing.T) {
+
+		c := gs.New()
+		c.Property("task.step", 2)
+		c.Property("task.rate", "@every 10ms")
+
+		clock := newManualClock()
+		c.Object(clock).Export((*gs.Clock)(nil))
+
+		counter := &scheduledCounter{ticks: make(chan struct{}, 1)}
+		c.Object(counter).Schedule("@delay 10ms", (*scheduledCounter).Tick, arg.R2("${task.step}"))
+
+		var calls int32
+		called := make(chan struct{}, 1)
+		c.Schedule("${task.rate}", func(ctx context.Context, s *scheduledCounter) {
+			n := atomic.AddInt32(&calls, 1)
+			called <- struct{}{}
+			if n == 1 {
+				panic("first call panics")
+			}
+		})
+
+		err := c.Refresh()
+		assert.Nil(t, err)
+
+		// 第一次执行发生 panic 之后仍然继续执行。
+		for i := 0; i < 3; i++ {
+			clock.wait(2)
+			clock.advance(10 * time.Millisecond)
+			<-called
+			<-counter.ticks
+		}
+		clock.wait(2)
+		c.Close()
+
+		assert.Equal(t, atomic.LoadInt32(&calls), int32(3))
+		assert.Equal(t, counter.get(), int32(6))
+
+		// 容器关闭之后不再执行。
+		clock.advance(10 * time.Millisecond)
+		assert.Equal(t, atomic.LoadInt32(&calls), int32(3))
+		assert.Equal(t, counter.get(), int32(6))
+	})
+
+	t.Run("overlap", func(t *testing.T) {
+
+		// 每次执行都阻塞到 release 关闭，第一次执行期间触发的两次执行按照策略处理。
+		run := func(policy gs.OverlapPolicy) (int32, int32) {
+			var calls, running, maxRunning int32
+			started := make(chan struct{}, 3)
+			release := make(chan struct{})
+			clock := newManualClock()
+			c := gs.New()
+			c.Object(clock).Export((*gs.Clock)(nil))
+			c.Schedule("@every 10ms", func() {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+				atomic.AddInt32(&calls, 1)
+				started <- struct{}{}
+				<-release
+			}).Overlap(policy)
+			err := c.Refresh()
+			assert.Nil(t, err)
+
+			clock.wait(1)
+			clock.advance(10 * time.Millisecond)
+			<-started
+			for i := 0; i < 2; i++ {
+				clock.wait(1)
+				clock.advance(10 * time.Millisecond)
+			}
+			clock.wait(1)
+
+			if policy == gs.OverlapConcurrent {
+				<-started
+				<-started
+			}
+			close(release)
+			if policy == gs.OverlapQueue {
+				<-started
+				<-started
+			}
+			c.Close()
+			return atomic.LoadInt32(&calls), atomic.LoadInt32(&maxRunning)
+		}
+
+		calls, maxRunning := run(gs.OverlapSkip)
+		assert.Equal(t, calls, int32(1))
+		assert.Equal(t, maxRunning, int32(1))
+
+		calls, maxRunning = run(gs.OverlapQueue)
+		assert.Equal(t, calls, int32(3))
+		assert.Equal(t, maxRunning, int32(1))
+
+		calls, maxRunning = run(gs.OverlapConcurrent)
+		assert.Equal(t, calls, int32(3))
+		assert.Equal(t, maxRunning, int32(3))
+	})
+
+	t.Run("invalid spec", func(t *testing.T) {
+		c := gs.New()
+		c.Schedule("0 32 * * *", func() {}).Name("report")
+		err := c.Refresh()
+		assert.Error(t, err, `schedule of task report error: invalid cron spec "0 32 \* \* \*": invalid value "32" of hour`)
+	})
+
+	t.Run("invalid task", func(t *testing.T) {
+		assert.Panic(t, func() {
+			gs.New().Schedule("@every 1s", func() int { return 0 })
+		}, `task should be func\(...\) or func\(...\)error`)
+		assert.Panic(t, func() {
+			gs.New().Object(&scheduledCounter{}).Schedule("@every 1s", func() {})
+		}, `task of bean should be func\(bean, ...\) or func\(bean, ...\)error`)
+	})
+}
+
+type asyncMailer interface {
+	Send(to string)
+}
+
+type asyncMailerImpl struct {
+	sent chan string
+}
+
+func (m *asyncMailerImpl) Send(to string) {
+	m.sent <- to
+}
+
+type asyncMailerProxy struct {
+	h gs.InvocationHandler
+}
+
+func (p *asyncMailerProxy) Send(to string) {
+	p.h.Invoke("Send", to)
+}
+
+func init() {
+	gs.RegisterProxy((*asyncMailer)(nil), func(h gs.InvocationHandler) interface{} {
+		return &asyncMailerProxy{h: h}
+	})
+}
+
+func TestApplicationContext_Executor(t *testing.T) {
+
+	t.Run("submit", func(t *testing.T) {
+
+		c := gs.New()
+		c.Property("executor.io.size", 2)
+		c.Property("executor.io.queue", 1)
+
+		// bean 的默认名称是 Executor 的名称。
+		s := &struct {
+			IO   *gs.Executor `autowire:"io"`
+			Mail *gs.Executor `autowire:"mail"`
+		}{}
+		c.Object(gs.NewExecutor("io"))
+		c.Object(gs.NewExecutor("mail"))
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.IO.Name(), "io")
+		assert.Equal(t, s.Mail.Name(), "mail")
+
+		release := make(chan struct{})
+		started := make(chan struct{}, 3)
+		var futures []*gs.Future
+		for i := 0; i < 3; i++ {
+			f, err := s.IO.Submit(func(ctx context.Context) {
+				started <- struct{}{}
+				<-release
+			})
+			assert.Nil(t, err)
+			futures = append(futures, f)
+			if i < 2 {
+				<-started
+			}
+		}
+
+		// 两个任务正在执行，一个任务正在排队，第四个任务被拒绝。
+		_, err = s.IO.Submit(func(ctx context.Context) {})
+		assert.True(t, errors.Is(err, gs.ErrRejected))
+
+		close(release)
+		for _, f := range futures {
+			assert.Nil(t, f.Wait(context.Background()))
+		}
+
+		f, err := s.IO.Submit(func(ctx context.Context) { panic("boom") })
+		assert.Nil(t, err)
+		assert.Error(t, f.Wait(context.Background()), "executor io task panic: boom")
+
+		stats := s.IO.Stats()
+		assert.Equal(t, stats.Size, 2)
+		assert.Equal(t, stats.Submitted, int64(4))
+		assert.Equal(t, stats.Completed, int64(4))
+		assert.Equal(t, stats.Failed, int64(1))
+		assert.Equal(t, stats.Rejected, int64(1))
+
+		c.Close()
+		_, err = s.IO.Submit(func(ctx context.Context) {})
+		assert.Error(t, err, "executor io is not running: task rejected")
+	})
+
+	t.Run("rejection policy", func(t *testing.T) {
+
+		run := func(policy string) (*gs.Future, error, bool) {
+			c := gs.New()
+			c.Property("executor.io.size", 1)
+			c.Property("executor.io.queue", 1)
+			c.Property("executor.io.rejection-policy", policy)
+			e := gs.NewExecutor("io")
+			c.Object(e)
+			err := c.Refresh()
+			assert.Nil(t, err)
+			defer c.Close()
+
+			release := make(chan struct{})
+			defer close(release)
+			started := make(chan struct{})
+			_, err = e.Submit(func(ctx context.Context) {
+				close(started)
+				<-release
+			})
+			assert.Nil(t, err)
+			<-started
+			_, err = e.Submit(func(ctx context.Context) {})
+			assert.Nil(t, err)
+
+			var called bool
+			f, err := e.Submit(func(ctx context.Context) { called = true })
+			return f, err, called
+		}
+
+		_, err, called := run(gs.RejectAbort)
+		assert.Error(t, err, "executor io queue is full: task rejected")
+		assert.False(t, called)
+
+		f, err, called := run(gs.RejectCallerRuns)
+		assert.Nil(t, err)
+		assert.True(t, called)
+		assert.Nil(t, f.Wait(context.Background()))
+
+		f, err, called = run(gs.RejectDiscard)
+		assert.Nil(t, err)
+		assert.False(t, called)
+		assert.Equal(t, f.Wait(context.Background()), gs.ErrRejected)
+
+		c := gs.New()
+		c.Property("executor.io.rejection-policy", "retry")
+		c.Object(gs.NewExecutor("io"))
+		err = c.Refresh()
+		assert.Error(t, err, "executor io: unknown rejection policy \"retry\"")
+	})
+
+	t.Run("shutdown", func(t *testing.T) {
+
+		c := gs.New()
+		c.Property("executor.io.size", 1)
+		c.Property(gs.SpringShutdownTimeout, "50ms")
+		e := gs.NewExecutor("io")
+		c.Object(e)
+		err := c.Refresh()
+		assert.Nil(t, err)
+
+		var finished int32
+		for i := 0; i < 2; i++ {
+			_, err = e.Submit(func(ctx context.Context) {
+				time.Sleep(10 * time.Millisecond)
+				atomic.AddInt32(&finished, 1)
+			})
+			assert.Nil(t, err)
+		}
+		_, err = e.Submit(func(ctx context.Context) { <-ctx.Done() })
+		assert.Nil(t, err)
+		_, err = e.Submit(func(ctx context.Context) {})
+		assert.Nil(t, err)
+
+		// 关闭时执行完排队的任务，超时后通知阻塞的任务退出。
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		err = e.OnDestroy(ctx)
+		assert.Error(t, err, "executor io stopped with 1 active and 1 queued tasks")
+		assert.Equal(t, atomic.LoadInt32(&finished), int32(2))
+		c.Close()
+	})
+
+	t.Run("caller runs while stopping", func(t *testing.T) {
+
+		c := gs.New()
+		c.Property("executor.io.size", 1)
+		c.Property("executor.io.queue", 1)
+		c.Property("executor.io.rejection-policy", gs.RejectCallerRuns)
+		e := gs.NewExecutor("io")
+		c.Object(e)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		defer c.Close()
+
+		started := make(chan struct{}, 2)
+		block := func(ctx context.Context) {
+			started <- struct{}{}
+			<-ctx.Done()
+		}
+		_, err = e.Submit(block)
+		assert.Nil(t, err)
+		<-started
+		_, err = e.Submit(block)
+		assert.Nil(t, err)
+
+		// 队列已满，任务在调用者的 goroutine 中执行。
+		go func() { _, _ = e.Submit(block) }()
+		<-started
+
+		// 在调用者的 goroutine 中执行的任务不会阻塞 OnDestroy 。
+		destroyed := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			destroyed <- e.OnDestroy(ctx)
+		}()
+		select {
+		case err = <-destroyed:
+			assert.Error(t, err, "executor io stopped with 2 active and 1 queued tasks")
+		case <-time.After(time.Second):
+			t.Fatal("OnDestroy is blocked by the task running in the caller")
+		}
+	})
+
+	t.Run("async", func(t *testing.T) {
+
+		c := gs.New()
+		e := gs.NewExecutor("mail")
+		c.Object(e)
+		mailer := &asyncMailerImpl{sent: make(chan string, 1)}
+		c.Object(mailer).Export((*asyncMailer)(nil))
+		c.Intercept((*asyncMailer)(nil), e.Async("Send"))
+
+		s := &struct {
+			Mailer asyncMailer `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+
+		s.Mailer.Send("tom")
+		assert.Equal(t, <-mailer.sent, "tom")
+		c.Close()
+	})
+}
+
+type starterCodec interface {
+	Encode(s string) string
+}
+
+type defaultCodec struct{}
+
+func (c *defaultCodec) Encode(s string) string { return "default:" + s }
+
+type customCodec struct{ name string }
+
+func (c *customCodec) Encode(s string) string { return c.name + ":" + s }
+
+type codecRegistry struct{}
+
+func (c *defaultCodec) Registry() *codecRegistry { return &codecRegistry{} }
+
+type codecMetrics struct{}
+
+func TestApplicationContext_Replaces(t *testing.T) {
+
+	t.Run("replaces", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&defaultCodec{}).Export((*starterCodec)(nil))
+		c.Object(&customCodec{name: "custom"}).Export((*starterCodec)(nil)).Replaces((*starterCodec)(nil))
+		// 条件不满足的 bean 不会替换其他 bean 。
+		c.Object(&customCodec{name: "disabled"}).Name("disabled").
+			Export((*starterCodec)(nil)).Replaces((*starterCodec)(nil)).On(cond.OnProperty("codec.enabled"))
+
+		s := &struct {
+			gs.ContextAware
+			Codec starterCodec `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Codec.Encode("a"), "custom:a")
+
+		var msgs []string
+		for _, o := range s.GSContext.ConditionReport() {
+			if o.Message != "" {
+				msgs = append(msgs, o.ID+" "+o.Message)
+			}
+		}
+		assert.Equal(t, len(msgs), 1)
+		assert.Matches(t, msgs[0], `gs_test.defaultCodec:defaultCodec replaced by object bean name:"customCodec" .*/gs_test.go:\d+$`)
+	})
+
+	t.Run("conditions", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&defaultCodec{}).Export((*starterCodec)(nil))
+		// 条件在替换之后评估，被替换的 bean 视为不存在。
+		c.Object(&codecMetrics{}).On(cond.OnMissingBean("defaultCodec"))
+		c.Object(&customCodec{name: "custom"}).Export((*starterCodec)(nil)).Replaces("defaultCodec")
+		s := &struct {
+			Metrics *codecMetrics `autowire:"?"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.NotNil(t, s.Metrics)
+	})
+
+	t.Run("method bean", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&defaultCodec{}).Name("codec")
+		c.Provide((*defaultCodec).Registry, "codec")
+		c.Object(&customCodec{name: "custom"}).Name("codec").Replaces("codec")
+		s := &struct {
+			Registry *codecRegistry `autowire:"?"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Nil(t, s.Registry)
+	})
+
+	t.Run("overriding", func(t *testing.T) {
+
+		register := func(c gs.Container) {
+			c.Object(&customCodec{name: "first"}).Name("codec").Export((*starterCodec)(nil))
+			c.Object(&customCodec{name: "second"}).Name("codec").Export((*starterCodec)(nil))
+		}
+
+		c := gs.New()
+		register(c)
+		err := c.Refresh()
+		assert.Error(t, err, "found duplicate beans")
+
+		c = gs.New()
+		c.Property(gs.SpringAllowBeanOverriding, true)
+		register(c)
+		s := &struct {
+			Codec starterCodec `autowire:""`
+		}{}
+		c.Object(s)
+		err = c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Codec.Encode("a"), "second:a")
+	})
+}
+
+type moduleRedis struct{}
+
+type moduleCache struct {
+	kind string
+}
+
+type moduleUserBean struct{}
+
+func TestApplicationContext_Module(t *testing.T) {
+
+	newModules := func(order *[]string) []*gs.Module {
+		return []*gs.Module{
+			gs.NewModule("cache", func(r gs.ModuleRegistry) {
+				*order = append(*order, "cache")
+				r.Object(&moduleCache{kind: "redis"}).Name("redisCache").On(cond.OnBean((*moduleRedis)(nil)))
+				r.Object(&moduleCache{kind: "local"}).Name("localCache").On(cond.OnMissingBean((*moduleCache)(nil)))
+			}).After("redis"),
+			gs.NewModule("redis", func(r gs.ModuleRegistry) {
+				*order = append(*order, "redis")
+				r.Object(&moduleRedis{})
+			}).On(cond.OnProperty("redis.enabled")),
+			gs.NewModule("metrics", func(r gs.ModuleRegistry) {
+				*order = append(*order, "metrics")
+			}).Before("redis"),
+		}
+	}
+
+	t.Run("order", func(t *testing.T) {
+		var order []string
+		c := gs.New()
+		c.Property("redis.enabled", true)
+		for _, m := range newModules(&order) {
+			c.Module(m)
+		}
+		// 用户注册的 bean 的条件看不到模块注册的 bean 。
+		c.Object(&moduleUserBean{}).On(cond.OnMissingBean((*moduleRedis)(nil)))
+		s := &struct {
+			gs.ContextAware
+			Cache *moduleCache    `autowire:""`
+			User  *moduleUserBean `autowire:"?"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, order, []string{"metrics", "redis", "cache"})
+		assert.Equal(t, s.Cache.kind, "redis")
+		assert.NotNil(t, s.User)
+	})
+
+	t.Run("user bean and exclusion", func(t *testing.T) {
+		var order []string
+		c := gs.New()
+		c.Property("redis.enabled", true)
+		c.Property(gs.SpringAutoconfigureExclude, "redis,metrics")
+		for _, m := range newModules(&order) {
+			c.Module(m)
+		}
+		c.Object(&moduleCache{kind: "user"})
+		s := &struct {
+			gs.ContextAware
+			Cache *moduleCache `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, order, []string{"cache"})
+		assert.Equal(t, s.Cache.kind, "user")
+
+		var msgs []string
+		for _, o := range s.GSContext.ConditionReport() {
+			if strings.HasPrefix(o.ID, "module:") {
+				msgs = append(msgs, fmt.Sprint(o.ID, " ", o.Matched, " ", o.Message))
+			}
+		}
+		assert.Equal(t, msgs, []string{
+			"module:cache true ",
+			"module:metrics false excluded by spring.autoconfigure.exclude",
+			"module:redis false excluded by spring.autoconfigure.exclude",
+		})
+	})
+
+	t.Run("condition", func(t *testing.T) {
+		var order []string
+		c := gs.New()
+		for _, m := range newModules(&order) {
+			c.Module(m)
+		}
+		s := &struct {
+			Cache *moduleCache `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, order, []string{"metrics", "cache"})
+		assert.Equal(t, s.Cache.kind, "local")
+	})
+
+	t.Run("cycle", func(t *testing.T) {
+		c := gs.New()
+		c.Module(gs.NewModule("a", func(r gs.ModuleRegistry) {}).After("b"))
+		c.Module(gs.NewModule("b", func(r gs.ModuleRegistry) {}).After("c"))
+		c.Module(gs.NewModule("c", func(r gs.ModuleRegistry) {}).Before("b").After("a"))
+		err := c.Refresh()
+		assert.Error(t, err, "found module cycle a -> b -> c -> a")
+	})
+}
+
+type endpointClient struct {
+	url string
+}
+
+type endpointClientFactory struct {
+	url string
+}
+
+func newEndpointClientFactory(url string) *endpointClientFactory {
+	return &endpointClientFactory{url: url}
+}
+
+func (f *endpointClientFactory) Object() (interface{}, error) {
+	if f.url == "" {
+		return nil, errors.New("empty url")
+	}
+	return &endpointClient{url: f.url}, nil
+}
+
+func (f *endpointClientFactory) ObjectType() reflect.Type {
+	return reflect.TypeOf((*endpointClient)(nil))
+}
+
+type endpointConfig struct {
+	URL string `value:"${url}"`
+}
+
+// endpointClientsFactory 为每个机房生产一个客户端。
+type endpointClientsFactory struct {
+	Endpoints map[string]endpointConfig `value:"${endpoints}"`
+}
+
+func (f *endpointClientsFactory) ObjectNames() []string {
+	return []string{"east", "west"}
+}
+
+func (f *endpointClientsFactory) ObjectType() reflect.Type {
+	return reflect.TypeOf((*endpointClient)(nil))
+}
+
+func (f *endpointClientsFactory) ObjectOf(name string) (interface{}, error) {
+	e, ok := f.Endpoints[name]
+	if !ok {
+		return nil, fmt.Errorf("no url of %s", name)
+	}
+	return &endpointClient{url: e.URL}, nil
+}
+
+func TestApplicationContext_FactoryBean(t *testing.T) {
+
+	t.Run("product", func(t *testing.T) {
+		c := gs.New()
+		c.Property("endpoints.east.url", "http://east")
+		c.Property("endpoints.west.url", "http://west")
+		for _, name := range []string{"east", "west"} {
+			c.Provide(newEndpointClientFactory, "${endpoints."+name+".url}").Name(name)
+		}
+		s := &struct {
+			East    *endpointClient        `autowire:"east"`
+			West    *endpointClient        `autowire:"west"`
+			Clients []*endpointClient      `autowire:""`
+			Factory *endpointClientFactory `autowire:"east"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.East.url, "http://east")
+		assert.Equal(t, s.West.url, "http://west")
+		assert.Equal(t, len(s.Clients), 2)
+		assert.Equal(t, s.Factory.url, "http://east")
+	})
+
+	t.Run("object", func(t *testing.T) {
+		c := gs.New()
+		c.Object(&endpointClientFactory{url: "http://local"})
+		s := &struct {
+			Client *endpointClient `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Client.url, "http://local")
+	})
+
+	t.Run("multi products", func(t *testing.T) {
+		c := gs.New()
+		c.Property("endpoints.east.url", "http://east")
+		c.Property("endpoints.west.url", "http://west")
+		c.Provide(func() *endpointClientsFactory { return new(endpointClientsFactory) })
+		s := &struct {
+			East    *endpointClient   `autowire:"east"`
+			West    *endpointClient   `autowire:"west"`
+			Clients []*endpointClient `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.East.url, "http://east")
+		assert.Equal(t, s.West.url, "http://west")
+		assert.Equal(t, len(s.Clients), 2)
+
+		c = gs.New()
+		c.Property("endpoints.east.url", "http://east")
+		c.Object(&endpointClientsFactory{})
+		c.Object(&struct {
+			West *endpointClient `autowire:"west"`
+		}{})
+		err = c.Refresh()
+		assert.Error(t, err, "no url of west")
+	})
+
+	t.Run("deleted factory", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(newEndpointClientFactory, "${url}").On(cond.OnProperty("url"))
+		s := &struct {
+			Client *endpointClient `autowire:"?"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Nil(t, s.Client)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		c := gs.New()
+		c.Provide(newEndpointClientFactory, "${url:=}")
+		c.Object(&struct {
+			Client *endpointClient `autowire:""`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, "empty url")
+	})
+}
+
+func TestApplicationContext_TypedConditions(t *testing.T) {
+	c := gs.New()
+	c.Property("server.port", 8080)
+	c.Property("mq.types", []string{"redis", "kafka"})
+	c.Property("app.version", "1.4.2")
+	c.Object(&BeanZero{1}).Name("expr").
+		On(cond.OnExpression("int(${server.port}) > 1024 && 'kafka' in ${mq.types}"))
+	c.Object(&BeanZero{2}).Name("version").
+		On(cond.OnProperty("app.version", cond.HavingVersion(">= 1.5")))
+	c.Object(&BeanZero{3}).Name("list").
+		On(cond.OnProperty("mq.types", cond.ContainingItem("redis")))
+	s := &struct {
+		Beans []*BeanZero `autowire:""`
+	}{}
+	c.Object(s)
+	err := c.Refresh()
+	assert.Nil(t, err)
+	var values []int
+	for _, b := range s.Beans {
+		values = append(values, b.Int)
+	}
+	sort.Ints(values)
+	assert.Equal(t, values, []int{1, 3})
+}
+
+type regionCache struct {
+	region string
+}
+
+func TestApplicationContext_Labels(t *testing.T) {
+
+	register := func(c gs.Container) {
+		c.Object(&regionCache{"eu-1"}).Name("eu1").Label("region", "eu").Qualifier("fast").Order(2)
+		c.Object(&regionCache{"eu-2"}).Name("eu2").Label("region", "eu").Order(1)
+		c.Object(&regionCache{"us-1"}).Name("us1").Label("region", "us").Qualifier("fast")
+	}
+
+	regions := func(caches []*regionCache) []string {
+		var r []string
+		for _, c := range caches {
+			r = append(r, c.region)
+		}
+		return r
+	}
+
+	t.Run("single", func(t *testing.T) {
+		c := gs.New()
+		register(c)
+		s := &struct {
+			Fast    *regionCache `autowire:"*?[region=eu][fast]"`
+			US      *regionCache `autowire:"[region=us]"`
+			Missing *regionCache `autowire:"*?[region=cn]"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Fast.region, "eu-1")
+		assert.Equal(t, s.US.region, "us-1")
+		assert.Nil(t, s.Missing)
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		c := gs.New()
+		register(c)
+		c.Object(&struct {
+			Cache *regionCache `autowire:"[region=eu]"`
+		}{})
+		err := c.Refresh()
+		assert.Error(t, err, `found 2 beans, bean:"\[region=eu\]"`)
+	})
+
+	t.Run("collection", func(t *testing.T) {
+		c := gs.New()
+		register(c)
+		s := &struct {
+			EU   []*regionCache          `autowire:"*?[region=eu]"`
+			Fast []*regionCache          `autowire:"[fast]"`
+			All  []*regionCache          `autowire:"us1,*[region=eu]"`
+			Map  map[string]*regionCache `autowire:"[region=eu]"`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, regions(s.EU), []string{"eu-2", "eu-1"})
+		assert.Equal(t, regions(s.Fast), []string{"us-1", "eu-1"})
+		assert.Equal(t, regions(s.All), []string{"us-1", "eu-2", "eu-1"})
+		assert.Equal(t, len(s.Map), 2)
+		assert.Equal(t, s.Map["eu2"].region, "eu-2")
+	})
+
+	t.Run("find", func(t *testing.T) {
+		c := gs.New()
+		register(c)
+		c.Object(&BeanZero{1}).On(cond.OnBean("[region=us][fast]"))
+		c.Object(&BeanZero{2}).On(cond.OnBean("[region=cn"))
+		err := c.Refresh()
+		assert.Error(t, err, `invalid bean selector "\[region=cn"`)
+	})
+}
+
+type serverParams struct {
+	arg.In
+	Port    int            `value:"${server.port}"`
+	Cache   *regionCache   `autowire:"eu1"`
+	Caches  []*regionCache `autowire:""`
+	Backup  *regionCache   `autowire:"cn1" optional:"true"`
+	Timeout int            `value:"${server.timeout}" optional:"true"`
+	Host    string         `value:"${server.host:=localhost}" optional:"true"`
+}
+
+type paramsServer struct {
+	params interface{}
+}
+
+func TestApplicationContext_ParamsStruct(t *testing.T) {
+
+	register := func(c gs.Container) {
+		c.Property("server.port", 8080)
+		c.Object(&regionCache{"eu-1"}).Name("eu1")
+		c.Object(&regionCache{"us-1"}).Name("us1")
+	}
+
+	for _, parallel := range []bool{false, true} {
+		c := gs.New()
+		register(c)
+		c.Property(gs.SpringRefreshParallel, parallel)
+		c.Provide(func(p serverParams) *paramsServer {
+			return &paramsServer{p}
+		})
+		s := &struct {
+			Server *paramsServer `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		p := s.Server.params.(serverParams)
+		assert.Equal(t, p.Port, 8080)
+		assert.Equal(t, p.Cache.region, "eu-1")
+		assert.Equal(t, len(p.Caches), 2)
+		assert.Nil(t, p.Backup)
+		assert.Equal(t, p.Timeout, 0)
+		assert.Equal(t, p.Host, "localhost")
+	}
+
+	t.Run("required", func(t *testing.T) {
+		c := gs.New()
+		c.Property("server.port", 8080)
+		c.Provide(func(p serverParams) *paramsServer {
+			return &paramsServer{p}
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "wire field Cache error")
+	})
+
+	t.Run("embedded", func(t *testing.T) {
+		type caches struct {
+			Cache *regionCache `autowire:"eu1"`
+		}
+		type params struct {
+			arg.In
+			caches
+		}
+		c := gs.New()
+		register(c)
+		c.Property(gs.SpringRefreshParallel, true)
+		c.Provide(func(p params) *paramsServer {
+			return &paramsServer{p}
+		})
+		s := &struct {
+			Server *paramsServer `autowire:""`
+		}{}
+		c.Object(s)
+		err := c.Refresh()
+		assert.Nil(t, err)
+		assert.Equal(t, s.Server.params.(params).Cache.region, "eu-1")
+	})
+
+	t.Run("lazy", func(t *testing.T) {
+		type params struct {
+			arg.In
+			Cache *regionCache `autowire:"eu1,lazy"`
+		}
+		c := gs.New()
+		register(c)
+		c.Provide(func(p params) *paramsServer {
+			return &paramsServer{p}
+		})
+		err := c.Refresh()
+		assert.Error(t, err, "lazy is not supported in params struct field Cache")
+	})
+}
@@ -0,0 +1,205 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gs
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StartupStep 是启动过程中的一个步骤，例如加载属性、刷新容器、创建 bean 等，
+// 子步骤的耗时包含在父步骤的耗时之内。创建 bean 的步骤包含了创建其依赖的 bean 的
+// 步骤，SelfDuration 是扣除这些依赖之后 bean 自身的耗时。
+type StartupStep struct {
+	Name         string         `json:"name"`
+	Bean         string         `json:"bean,omitempty"` // 创建 bean 的步骤记录 bean 的 ID
+	Start        time.Time      `json:"start"`
+	Duration     time.Duration  `json:"duration"`
+	SelfDuration time.Duration  `json:"selfDuration"`
+	Children     []*StartupStep `json:"children,omitempty"`
+}
+
+// StartupTimeline 记录应用启动过程中各个阶段以及每个 bean 的构造函数、依赖注
+// 入和初始化函数的耗时，用于查找拖慢启动速度的 bean 。
+type StartupTimeline struct {
+	mutex sync.Mutex
+	steps []*StartupStep
+}
+
+// begin 开始一个步骤，parent 为空时是顶层步骤，创建 bean 的步骤需要指定 bean 。
+func (t *StartupTimeline) begin(parent *StartupStep, name string, bean string) *StartupStep {
+	s := &StartupStep{Name: name, Bean: bean, Start: time.Now()}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if parent == nil {
+		t.steps = append(t.steps, s)
+	} else {
+		parent.Children = append(parent.Children, s)
+	}
+	return s
+}
+
+// end 结束一个步骤。
+func (t *StartupTimeline) end(s *StartupStep) {
+	d := time.Since(s.Start)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	s.Duration = d
+	s.SelfDuration = d - unionDuration(nestedBeans(s.Children, nil))
+	if s.SelfDuration < 0 {
+		s.SelfDuration = 0
+	}
+}
+
+// nestedBeans 返回 steps 中最外层的创建 bean 的步骤。
+func nestedBeans(steps []*StartupStep, result []*StartupStep) []*StartupStep {
+	for _, s := range steps {
+		if s.Bean != "" {
+			result = append(result, s)
+		} else {
+			result = nestedBeans(s.Children, result)
+		}
+	}
+	return result
+}
+
+// unionDuration 返回 steps 的时间区间的并集的长度，并行创建的 bean 的时间区间
+// 可能相互重叠。
+func unionDuration(steps []*StartupStep) time.Duration {
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Start.Before(steps[j].Start)
+	})
+	var (
+		d          time.Duration
+		start, end time.Time
+	)
+	for _, s := range steps {
+		e := s.Start.Add(s.Duration)
+		if s.Start.After(end) {
+			d += end.Sub(start)
+			start, end = s.Start, e
+		} else if e.After(end) {
+			end = e
+		}
+	}
+	return d + end.Sub(start)
+}
+
+// Steps 返回所有的顶层步骤。
+func (t *StartupTimeline) Steps() []*StartupStep {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return append([]*StartupStep(nil), t.steps...)
+}
+
+// Find 按照深度优先的顺序查找名称为 name 的第一个步骤。
+func (t *StartupTimeline) Find(name string) *StartupStep {
+	var r *StartupStep
+	t.walk(func(s *StartupStep) bool {
+		if s.Name == name {
+			r = s
+			return false
+		}
+		return true
+	})
+	return r
+}
+
+// Beans 返回所有创建 bean 的步骤，按照 bean 自身的耗时从高到低排序，因此依赖较
+// 多的 bean 不会因为包含了依赖的耗时而排在前面。
+func (t *StartupTimeline) Beans() []*StartupStep {
+	var r []*StartupStep
+	t.walk(func(s *StartupStep) bool {
+		if s.Bean != "" {
+			r = append(r, s)
+		}
+		return true
+	})
+	sort.SliceStable(r, func(i, j int) bool {
+		return r[i].SelfDuration > r[j].SelfDuration
+	})
+	return r
+}
+
+// walk 按照深度优先的顺序遍历所有步骤，fn 返回 false 时停止遍历。
+func (t *StartupTimeline) walk(fn func(s *StartupStep) bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var visit func(steps []*StartupStep) bool
+	visit = func(steps []*StartupStep) bool {
+		for _, s := range steps {
+			if !fn(s) || !visit(s.Children) {
+				return false
+			}
+		}
+		return true
+	}
+	visit(t.steps)
+}
+
+// flameNode 是火焰图的节点，value 是以微秒为单位的耗时。
+type flameNode struct {
+	Name     string       `json:"name"`
+	Value    int64        `json:"value"`
+	Children []*flameNode `json:"children,omitempty"`
+}
+
+// JSON 返回火焰图格式的启动时间线，根节点是 startup，每个节点的 value 是以微
+// 秒为单位的耗时，可以直接用于 d3-flame-graph 等工具。
+func (t *StartupTimeline) JSON() ([]byte, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var convert func(steps []*StartupStep) []*flameNode
+	convert = func(steps []*StartupStep) []*flameNode {
+		var r []*flameNode
+		for _, s := range steps {
+			r = append(r, &flameNode{
+				Name:     s.Name,
+				Value:    s.Duration.Microseconds(),
+				Children: convert(s.Children),
+			})
+		}
+		return r
+	}
+	root := &flameNode{Name: "startup", Children: convert(t.steps)}
+	for _, n := range root.Children {
+		root.Value += n.Value
+	}
+	return json.Marshal(root)
+}
+
+// Timeline 返回启动时间线。
+func (c *container) Timeline() *StartupTimeline {
+	return c.timeline
+}
+
+// beginStep 在注入路径的当前步骤下开始一个子步骤，返回值用于结束该步骤。只记
+// 录容器刷新过程中的步骤，运行时通过 Get 等方法注入的 bean 不记录。
+func (c *container) beginStep(stack *wiringStack, name string, bean string) func() {
+	if c.state != Refreshing || stack.step == nil {
+		return func() {}
+	}
+	parent := stack.step
+	s := c.timeline.begin(parent, name, bean)
+	stack.step = s
+	return func() {
+		c.timeline.end(s)
+		stack.step = parent
+	}
+}
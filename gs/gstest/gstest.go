@@ -0,0 +1,160 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+// Package gstest 用于编写基于容器的集成测试，每个测试使用独立的 App，不受 boot.go
+// 中全局 App 以及全局自动配置模块的影响，测试结束时自动关闭。
+package gstest
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-spring/spring-base/util"
+	"github.com/go-spring/spring-core/gs"
+)
+
+// StartTimeout 等待 App 启动的超时时间。
+var StartTimeout = 10 * time.Second
+
+type mockBean struct {
+	selector util.BeanSelector
+	mock     interface{}
+}
+
+type property struct {
+	key   string
+	value interface{}
+}
+
+type options struct {
+	registers     []func(app *gs.App)
+	props         []property
+	mocks         []mockBean
+	globalModules bool
+}
+
+// Option 设置测试 App 的选项。
+type Option func(opts *options)
+
+// Register 添加注册 bean 的函数，例如业务代码中的 func(app *gs.App) 形式的注册函数，
+// 这些函数按照添加的顺序执行。
+func Register(fns ...func(app *gs.App)) Option {
+	return func(opts *options) {
+		opts.registers = append(opts.registers, fns...)
+	}
+}
+
+// Property 设置测试属性，它们的优先级高于配置文件、环境变量和命令行参数。
+func Property(key string, value interface{}) Option {
+	return func(opts *options) {
+		opts.props = append(opts.props, property{key: key, value: value})
+	}
+}
+
+// GlobalModules 使用通过 gs.RegisterModule 注册的全局自动配置模块，默认情况下测
+// 试 App 只使用通过 app.Module 添加的模块。
+func GlobalModules() Option {
+	return func(opts *options) {
+		opts.globalModules = true
+	}
+}
+
+// Mock 使用 mock 对象替换符合选择器的 bean 。选择器是接口类型时 mock 对象导出该
+// 接口，选择器是字符串时作为 mock 对象的名称，因此通过接口或者名称注入的地方都会
+// 注入 mock 对象。需要注意的是注入点的类型是具体类型时无法使用 mock 对象替换。
+func Mock(selector util.BeanSelector, mock interface{}) Option {
+	return func(opts *options) {
+		opts.mocks = append(opts.mocks, mockBean{selector: selector, mock: mock})
+	}
+}
+
+// starter 在 App 启动之后通知测试代码，它注入的 Context 使得容器在启动后仍然可以
+// 获取 bean 。
+type starter struct {
+	gs.ContextAware
+	started chan struct{}
+}
+
+func (s *starter) OnAppStart(ctx gs.Context) { close(s.started) }
+
+func (s *starter) OnAppStop(ctx context.Context) {}
+
+// Run 创建并启动独立的 App，返回可以获取 bean 和属性的 Context，App 在测试结束时
+// 通过 t.Cleanup 关闭，关闭过程中发生的错误报告为测试失败。
+func Run(t testing.TB, opts ...Option) gs.Context {
+	t.Helper()
+
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	app := gs.NewApp()
+	if !o.globalModules {
+		app.IgnoreGlobalModules()
+	}
+	for _, fn := range o.registers {
+		fn(app)
+	}
+	for _, p := range o.props {
+		app.OverrideProperty(p.key, p.value)
+	}
+	for _, m := range o.mocks {
+		registerMock(app, m)
+	}
+
+	s := &starter{started: make(chan struct{})}
+	app.Object(s).Export((*gs.AppEvent)(nil))
+
+	exited := make(chan error, 1)
+	go func() { exited <- app.Run() }()
+
+	select {
+	case <-s.started:
+	case err := <-exited:
+		t.Fatalf("app start error: %v", err)
+	case <-time.After(StartTimeout):
+		app.ShutDown("start timeout")
+		t.Fatalf("app start timeout after %v", StartTimeout)
+	}
+
+	t.Cleanup(func() {
+		app.ShutDown("test end")
+		if err := <-exited; err != nil {
+			t.Errorf("app shutdown error: %v", err)
+		}
+	})
+	return s.GSContext
+}
+
+func registerMock(app *gs.App, m mockBean) {
+	b := app.Object(m.mock).Replaces(m.selector)
+	switch s := m.selector.(type) {
+	case string:
+		b.Name(s[strings.LastIndex(s, ":")+1:])
+	case reflect.Type:
+		if s.Kind() == reflect.Interface {
+			b.Export(reflect.New(s).Interface())
+		}
+	default:
+		if t := reflect.TypeOf(s); t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Interface {
+			b.Export(s)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+ * Copyright 2012-2019 the original author or authors.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *      https://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package gstest_test
+
+import (
+	"testing"
+
+	"github.com/go-spring/spring-base/assert"
+	"github.com/go-spring/spring-core/gs"
+	"github.com/go-spring/spring-core/gs/gstest"
+)
+
+type UserRepo interface {
+	FindName(id int) string
+}
+
+type dbUserRepo struct {
+	closed bool
+}
+
+func (r *dbUserRepo) FindName(id int) string { return "db" }
+
+type mockUserRepo struct{}
+
+func (r *mockUserRepo) FindName(id int) string { return "mock" }
+
+type UserService struct {
+	Repo   UserRepo `autowire:""`
+	Prefix string   `value:"${user.prefix:=user}"`
+}
+
+func (s *UserService) Greet(id int) string {
+	return s.Prefix + ":" + s.Repo.FindName(id)
+}
+
+func register(repo *dbUserRepo) func(app *gs.App) {
+	return func(app *gs.App) {
+		app.Object(repo).Export((*UserRepo)(nil)).Destroy(func(r *dbUserRepo) {
+			r.closed = true
+		})
+		app.Object(new(UserService))
+	}
+}
+
+type greeting struct{}
+
+func init() {
+	gs.RegisterModule(gs.NewModule("gstest-greeting", func(r gs.ModuleRegistry) {
+		r.Object(&greeting{})
+	}))
+}
+
+func TestRun(t *testing.T) {
+
+	t.Run("real", func(t *testing.T) {
+		repo := &dbUserRepo{}
+		t.Run("app", func(t *testing.T) {
+			ctx := gstest.Run(t, gstest.Register(register(repo)))
+			var s *UserService
+			assert.Nil(t, ctx.Get(&s))
+			assert.Equal(t, s.Greet(1), "user:db")
+			assert.False(t, repo.closed)
+		})
+		// 子测试结束时关闭 App 。
+		assert.True(t, repo.closed)
+	})
+
+	t.Run("mock", func(t *testing.T) {
+		repo := &dbUserRepo{}
+		ctx := gstest.Run(t,
+			gstest.Register(register(repo)),
+			gstest.Mock((*UserRepo)(nil), &mockUserRepo{}),
+			gstest.Property("user.prefix", "test"),
+		)
+		var s *UserService
+		assert.Nil(t, ctx.Get(&s))
+		assert.Equal(t, s.Greet(1), "test:mock")
+		assert.Equal(t, ctx.Prop("user.prefix"), "test")
+	})
+
+	t.Run("global modules", func(t *testing.T) {
+		var g *greeting
+		ctx := gstest.Run(t)
+		assert.Error(t, ctx.Get(&g), "can't find bean")
+		ctx = gstest.Run(t, gstest.GlobalModules())
+		assert.Nil(t, ctx.Get(&g))
+	})
+}